@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// bookkeeping columns that change on every write
+var ignoredFields = map[string]bool{
+	"CreatedAt": true,
+	"UpdatedAt": true,
+	"DeletedAt": true,
+}
+
+// Diff compares the JSON form of two values field by field, so whatever a
+// model hides from its JSON, such as password hashes, never reaches the audit
+// log. Either side may be nil for a create or a delete.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+
+	for key, value := range to {
+		if ignoredFields[key] {
+			continue
+		}
+
+		if old, ok := from[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes[key] = Change{From: from[key], To: value}
+		}
+	}
+
+	for key, value := range from {
+		if _, ok := to[key]; !ok && !ignoredFields[key] {
+			changes[key] = Change{From: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func fields(value interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return result, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
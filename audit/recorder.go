@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type Event struct {
+	// ActorID defaults to the authenticated user of the request
+	ActorID      string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       interface{}
+	After        interface{}
+}
+
+// Recorder writes audit events. Recording never fails the request, a failed
+// write is only logged.
+type Recorder interface {
+	Record(c *fiber.Ctx, event Event)
+}
+
+type implRecorder struct {
+	db *gorm.DB
+}
+
+func NewRecorder(db *gorm.DB) Recorder {
+	return &implRecorder{
+		db: db,
+	}
+}
+
+func (r *implRecorder) Record(c *fiber.Ctx, event Event) {
+	record := &model.AuditEvent{
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		IPAddress:    c.IP(),
+		UserAgent:    c.Get(fiber.HeaderUserAgent),
+		CreatedAt:    time.Now(),
+	}
+
+	actorID := event.ActorID
+	if actorID == "" {
+		actorID, _ = c.Locals("user_id").(string)
+	}
+	if actorID != "" {
+		record.ActorID = &actorID
+	}
+
+	if len(record.UserAgent) > 255 {
+		record.UserAgent = record.UserAgent[:255]
+	}
+
+	changes, err := Diff(event.Before, event.After)
+	if err != nil {
+		log.Printf("failed to diff audit event %s: %v", event.Action, err)
+	}
+
+	if len(changes) > 0 {
+		record.Changes, _ = json.Marshal(changes)
+	}
+
+	if err := r.db.Create(record).Error; err != nil {
+		log.Printf("failed to record audit event %s: %v", event.Action, err)
+	}
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const apiKeyPrefix = "ak_"
+
+// GenerateAPIKey returns a new key of the form ak_<prefix>.<secret>. The
+// prefix is stored in the clear to find the key, the secret only as a hash.
+func GenerateAPIKey() (prefix, key, secretHash string, err error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+
+	secret, err := RandomToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(buf)
+
+	return prefix, apiKeyPrefix + prefix + "." + secret, HashToken(secret), nil
+}
+
+// ParseAPIKey splits a key into its prefix and the hash of its secret.
+func ParseAPIKey(key string) (prefix, secretHash string, ok bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", "", false
+	}
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), ".")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+
+	return prefix, HashToken(secret), true
+}
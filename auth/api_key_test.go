@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	prefix, key, secretHash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, apiKeyPrefix+prefix+".") || len(prefix) != 12 {
+		t.Errorf("key = %s with prefix %s, want ak_<12 hex characters>.<secret>", key, prefix)
+	}
+
+	parsedPrefix, parsedHash, ok := ParseAPIKey(key)
+	if !ok || parsedPrefix != prefix || parsedHash != secretHash {
+		t.Errorf("ParseAPIKey(key) = %s, %s, %v, want the generated prefix and hash", parsedPrefix, parsedHash, ok)
+	}
+
+	if strings.Contains(key, secretHash) {
+		t.Error("key contains the stored hash")
+	}
+
+	_, other, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other == key {
+		t.Error("two generated keys are equal")
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantPrefix string
+		wantOK     bool
+	}{
+		{"valid key", "ak_0123456789ab.secret", "0123456789ab", true},
+		{"secret containing a dot", "ak_0123456789ab.sec.ret", "0123456789ab", true},
+		{"empty", "", "", false},
+		{"missing ak_ prefix", "0123456789ab.secret", "", false},
+		{"uppercase prefix", "AK_0123456789ab.secret", "", false},
+		{"missing separator", "ak_0123456789absecret", "", false},
+		{"empty key prefix", "ak_.secret", "", false},
+		{"empty secret", "ak_0123456789ab.", "", false},
+		{"bearer token", "eyJhbGciOiJIUzI1NiJ9.e30.sig", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prefix, secretHash, ok := ParseAPIKey(test.key)
+
+			if ok != test.wantOK || prefix != test.wantPrefix {
+				t.Fatalf("ParseAPIKey = %q, %v, want %q, %v", prefix, ok, test.wantPrefix, test.wantOK)
+			}
+
+			if ok && secretHash != HashToken(test.key[strings.Index(test.key, ".")+1:]) {
+				t.Errorf("secret hash = %s, want the hash of the part after the first dot", secretHash)
+			}
+		})
+	}
+}
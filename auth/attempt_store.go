@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+type implMemoryAttemptStore struct {
+	mu         sync.Mutex
+	records    map[string]*AttemptRecord
+	lastPruned time.Time
+}
+
+func NewMemoryAttemptStore() AttemptStore {
+	return &implMemoryAttemptStore{
+		records: make(map[string]*AttemptRecord),
+	}
+}
+
+func (s *implMemoryAttemptStore) Get(key string) (*AttemptRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := *record
+	return &copied, nil
+}
+
+func (s *implMemoryAttemptStore) Increment(key string, seen *AttemptRecord, now time.Time, resetAfter time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now, resetAfter)
+
+	record, ok := s.records[key]
+
+	switch {
+	case !ok && seen != nil, ok && seen == nil:
+		return false, nil
+	case ok && (record.Failures != seen.Failures || !record.LastFailureAt.Equal(seen.LastFailureAt)):
+		return false, nil
+	}
+
+	if !ok || now.Sub(record.LastFailureAt) > resetAfter {
+		record = &AttemptRecord{Key: key}
+		s.records[key] = record
+	}
+
+	record.Failures++
+	record.LastFailureAt = now
+
+	return true, nil
+}
+
+func (s *implMemoryAttemptStore) Decrement(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Failures > 0 {
+		record.Failures--
+	}
+
+	return nil
+}
+
+func (s *implMemoryAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		record.LockedUntil = &until
+	}
+
+	return nil
+}
+
+func (s *implMemoryAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// prune drops stale counters at most once a minute so the map cannot grow
+// without bound.
+func (s *implMemoryAttemptStore) prune(now time.Time, resetAfter time.Duration) {
+	if now.Sub(s.lastPruned) < time.Minute {
+		return
+	}
+
+	for key, record := range s.records {
+		locked := record.LockedUntil != nil && record.LockedUntil.After(now)
+		if !locked && now.Sub(record.LastFailureAt) > resetAfter {
+			delete(s.records, key)
+		}
+	}
+
+	s.lastPruned = now
+}
+
+type implPostgresAttemptStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresAttemptStore(db *gorm.DB) AttemptStore {
+	return &implPostgresAttemptStore{
+		db: db,
+	}
+}
+
+func (s *implPostgresAttemptStore) Get(key string) (*AttemptRecord, error) {
+	attempt := &model.LoginAttempt{}
+
+	if err := s.db.Where("key = ?", key).First(attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return toAttemptRecord(attempt), nil
+}
+
+func (s *implPostgresAttemptStore) Increment(key string, seen *AttemptRecord, now time.Time, resetAfter time.Duration) (bool, error) {
+	if seen == nil {
+		result := s.db.Exec(`
+			INSERT INTO login_attempts (key, failures, last_failure_at) VALUES (?, 1, ?)
+			ON CONFLICT (key) DO NOTHING
+		`, key, now)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	// failures and last_failure_at together tell whether anyone counted since
+	result := s.db.Exec(`
+		UPDATE login_attempts SET
+			failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+			locked_until = CASE WHEN last_failure_at < ? THEN NULL ELSE locked_until END,
+			last_failure_at = ?
+		WHERE key = ? AND failures = ? AND last_failure_at = ?
+	`, now.Add(-resetAfter), now.Add(-resetAfter), now, key, seen.Failures, seen.LastFailureAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (s *implPostgresAttemptStore) Decrement(key string) error {
+	return s.db.Exec("UPDATE login_attempts SET failures = failures - 1 WHERE key = ? AND failures > 0", key).Error
+}
+
+func (s *implPostgresAttemptStore) Lock(key string, until time.Time) error {
+	return s.db.Model(&model.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (s *implPostgresAttemptStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&model.LoginAttempt{}).Error
+}
+
+func toAttemptRecord(attempt *model.LoginAttempt) *AttemptRecord {
+	return &AttemptRecord{
+		Key:           attempt.Key,
+		Failures:      attempt.Failures,
+		LastFailureAt: attempt.LastFailureAt,
+		LockedUntil:   attempt.LockedUntil,
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrTokenExpired = errors.New("token has expired")
+	ErrTokenInvalid = errors.New("token is invalid")
+)
+
+// TokenTypeAccess is the typ header of access tokens (RFC 9068). Other signed
+// tokens carry a different typ so they can never be used as an access token.
+const TokenTypeAccess = "at+jwt"
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Role      int      `json:"role"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+}
+
+type ClaimsConfig struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+type TokenVerifier interface {
+	Verify(tokenString string) (*Claims, error)
+}
+
+type implTokenVerifier struct {
+	keys   KeyManager
+	config ClaimsConfig
+	parser *jwt.Parser
+}
+
+func NewTokenVerifier(keys KeyManager, config ClaimsConfig) TokenVerifier {
+	return &implTokenVerifier{
+		keys:   keys,
+		config: config,
+		// claims are validated by hand below so the leeway applies to every time claim
+		parser: jwt.NewParser(jwt.WithoutClaimsValidation()),
+	}
+}
+
+func (v *implTokenVerifier) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := v.parser.ParseWithClaims(tokenString, claims, v.keys.Keyfunc)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ != TokenTypeAccess {
+		return nil, ErrTokenInvalid
+	}
+
+	if err := v.config.Validate(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (config ClaimsConfig) Validate(claims *Claims, now time.Time) error {
+	if !claims.VerifyExpiresAt(now.Add(-config.Leeway), true) {
+		return ErrTokenExpired
+	}
+
+	if !claims.VerifyNotBefore(now.Add(config.Leeway), false) {
+		return ErrTokenInvalid
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(config.Leeway), true) {
+		return ErrTokenInvalid
+	}
+
+	if config.Issuer != "" && !claims.VerifyIssuer(config.Issuer, true) {
+		return ErrTokenInvalid
+	}
+
+	if config.Audience != "" && !claims.VerifyAudience(config.Audience, true) {
+		return ErrTokenInvalid
+	}
+
+	if claims.Subject == "" {
+		return ErrTokenInvalid
+	}
+
+	return nil
+}
+
+func (config ClaimsConfig) registered(subject string, issuedAt time.Time, ttl time.Duration) jwt.RegisteredClaims {
+	claims := jwt.RegisteredClaims{
+		Issuer:    config.Issuer,
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(issuedAt),
+		NotBefore: jwt.NewNumericDate(issuedAt),
+		ExpiresAt: jwt.NewNumericDate(issuedAt.Add(ttl)),
+	}
+
+	if config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{config.Audience}
+	}
+
+	return claims
+}
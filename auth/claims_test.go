@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestClaimsConfigValidate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	config := ClaimsConfig{Issuer: "https://app.example.com", Audience: "api", Leeway: 30 * time.Second}
+
+	valid := func() *Claims {
+		return &Claims{RegisteredClaims: config.registered("user", now.Add(-time.Minute), 15*time.Minute)}
+	}
+
+	tests := []struct {
+		name   string
+		config ClaimsConfig
+		modify func(claims *Claims)
+		want   error
+	}{
+		{"valid", config, func(*Claims) {}, nil},
+		{"expired", config, func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }, ErrTokenExpired},
+		{"expired within the leeway", config, func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }, nil},
+		{"without expiry", config, func(c *Claims) { c.ExpiresAt = nil }, ErrTokenExpired},
+		{"not yet valid", config, func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) }, ErrTokenInvalid},
+		{"not yet valid within the leeway", config, func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second)) }, nil},
+		{"issued in the future", config, func(c *Claims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) }, ErrTokenInvalid},
+		{"without issued at", config, func(c *Claims) { c.IssuedAt = nil }, ErrTokenInvalid},
+		{"wrong issuer", config, func(c *Claims) { c.Issuer = "https://evil.example.com" }, ErrTokenInvalid},
+		{"wrong audience", config, func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }, ErrTokenInvalid},
+		{"one of several audiences", config, func(c *Claims) { c.Audience = jwt.ClaimStrings{"other", "api"} }, nil},
+		{"without subject", config, func(c *Claims) { c.Subject = "" }, ErrTokenInvalid},
+		{"issuer and audience not configured", ClaimsConfig{}, func(c *Claims) { c.Issuer, c.Audience = "", nil }, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := valid()
+			test.modify(claims)
+
+			if err := test.config.Validate(claims, now); !errors.Is(err, test.want) {
+				t.Errorf("Validate = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenVerifier(t *testing.T) {
+	keys, err := NewKeyManager(KeyConfig{Algorithm: "HS256", SecretKey: []byte("test-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := ClaimsConfig{Issuer: "https://app.example.com", Audience: "api"}
+	verifier := NewTokenVerifier(keys, config)
+
+	sign := func(typ string, claims *Claims) string {
+		token, err := keys.Sign(typ, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	access := &Claims{RegisteredClaims: config.registered("user", time.Now(), time.Minute), Roles: []string{"admin"}, SessionID: "session"}
+	expired := &Claims{RegisteredClaims: config.registered("user", time.Now().Add(-time.Hour), time.Minute)}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"access token", sign(TokenTypeAccess, access), nil},
+		{"other token type", sign(PurposeEmailVerification+"+jwt", access), ErrTokenInvalid},
+		{"expired", sign(TokenTypeAccess, expired), ErrTokenExpired},
+		{"garbage", "not.a.token", ErrTokenInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := verifier.Verify(test.token)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("Verify = %v, want %v", err, test.want)
+			}
+
+			if test.want == nil && (claims.Subject != "user" || claims.SessionID != "session" || len(claims.Roles) != 1) {
+				t.Errorf("claims = %+v, want the signed ones", claims)
+			}
+		})
+	}
+}
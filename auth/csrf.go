@@ -0,0 +1,9 @@
+package auth
+
+// Cookie sessions use the double-submit pattern: the session sets a readable
+// csrf_token cookie and mutating requests must echo it in the X-CSRF-Token
+// header, which a cross-site form or script cannot do.
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
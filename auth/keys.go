@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type KeyConfig struct {
+	Algorithm      string
+	SecretKey      []byte
+	PrivateKeyFile string
+	PublicKeysDir  string
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager owns the key tokens are signed with and every key they may be
+// verified with. During a rotation the previous public keys stay in the
+// verification set so tokens signed before the switch keep working.
+type KeyManager interface {
+	Sign(typ string, claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+	JWKS() JWKSet
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+	jwk    *JWK
+}
+
+type implKeyManager struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	signingKid string
+	keys       map[string]verificationKey
+}
+
+func NewKeyManager(config KeyConfig) (KeyManager, error) {
+	m := &implKeyManager{
+		keys: make(map[string]verificationKey),
+	}
+
+	switch config.Algorithm {
+	case "", "HS256":
+		if len(config.SecretKey) == 0 {
+			return nil, errors.New("HS256 requires a secret key")
+		}
+
+		m.method = jwt.SigningMethodHS256
+		m.signingKey = config.SecretKey
+		m.keys[""] = verificationKey{method: jwt.SigningMethodHS256, key: config.SecretKey}
+
+		return m, nil
+	case "RS256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", config.Algorithm)
+	}
+
+	if config.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("%s requires a private key file", config.Algorithm)
+	}
+
+	data, err := os.ReadFile(config.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var public crypto.PublicKey
+
+	if config.Algorithm == "RS256" {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", config.PrivateKeyFile, err)
+		}
+
+		m.method = jwt.SigningMethodRS256
+		m.signingKey = private
+		public = &private.PublicKey
+	} else {
+		private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", config.PrivateKeyFile, err)
+		}
+
+		m.method = jwt.SigningMethodEdDSA
+		m.signingKey = private
+		public = private.(ed25519.PrivateKey).Public()
+	}
+
+	m.signingKid, err = m.addPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.PublicKeysDir != "" {
+		if err := m.loadPublicKeys(config.PublicKeysDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *implKeyManager) Sign(typ string, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["typ"] = typ
+
+	if m.signingKid != "" {
+		token.Header["kid"] = m.signingKid
+	}
+
+	return token.SignedString(m.signingKey)
+}
+
+func (m *implKeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.key, nil
+}
+
+func (m *implKeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+
+	for _, key := range m.keys {
+		if key.jwk != nil {
+			set.Keys = append(set.Keys, *key.jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func (m *implKeyManager) loadPublicKeys(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return fmt.Errorf("no PEM data in %s", file)
+		}
+
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		if _, err := m.addPublicKey(public); err != nil {
+			return fmt.Errorf("failed to load %s: %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *implKeyManager) addPublicKey(public crypto.PublicKey) (string, error) {
+	var jwk *JWK
+	var method jwt.SigningMethod
+	var thumbprint []byte
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		jwk = &JWK{
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+		method = jwt.SigningMethodRS256
+		thumbprint, _ = json.Marshal(map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N})
+	case ed25519.PublicKey:
+		jwk = &JWK{
+			Kty: "OKP",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+		method = jwt.SigningMethodEdDSA
+		thumbprint, _ = json.Marshal(map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X})
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	// RFC 7638 thumbprint, so the kid is stable no matter which file a key came from
+	sum := sha256.Sum256(thumbprint)
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	jwk.Use = "sig"
+
+	m.keys[jwk.Kid] = verificationKey{method: method, key: public, jwk: jwk}
+
+	return jwk.Kid, nil
+}
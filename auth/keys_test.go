@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// writeKey writes a private key to dir and its public key to dir/public, and
+// returns the private key file.
+func writeKey(t *testing.T, dir, name string, private interface{}, public interface{}) string {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "public"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "public", name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func writeRSAKey(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeKey(t, dir, name, key, &key.PublicKey)
+}
+
+func writeEd25519Key(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeKey(t, dir, name, private, public)
+}
+
+func signTestToken(t *testing.T, keys KeyManager) string {
+	t.Helper()
+
+	token, err := keys.Sign(TokenTypeAccess, &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestKeyManagerSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		config   KeyConfig
+		wantJWKS int
+	}{
+		{"HS256", KeyConfig{Algorithm: "HS256", SecretKey: []byte("secret")}, 0},
+		{"default algorithm", KeyConfig{SecretKey: []byte("secret")}, 0},
+		{"RS256", KeyConfig{Algorithm: "RS256", PrivateKeyFile: writeRSAKey(t, dir, "rsa")}, 1},
+		{"EdDSA", KeyConfig{Algorithm: "EdDSA", PrivateKeyFile: writeEd25519Key(t, dir, "ed25519")}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := NewKeyManager(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := jwt.Parse(signTestToken(t, keys), keys.Keyfunc)
+			if err != nil {
+				t.Fatalf("token does not verify: %v", err)
+			}
+
+			if token.Header["typ"] != TokenTypeAccess {
+				t.Errorf("typ = %v, want %s", token.Header["typ"], TokenTypeAccess)
+			}
+
+			set := keys.JWKS()
+			if len(set.Keys) != test.wantJWKS {
+				t.Fatalf("JWKS has %d keys, want %d", len(set.Keys), test.wantJWKS)
+			}
+
+			if test.wantJWKS > 0 && (token.Header["kid"] != set.Keys[0].Kid || set.Keys[0].Alg != token.Method.Alg()) {
+				t.Errorf("kid %v / alg %s does not match the published key %+v", token.Header["kid"], token.Method.Alg(), set.Keys[0])
+			}
+		})
+	}
+}
+
+func TestKeyManagerRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	old, err := NewKeyManager(KeyConfig{Algorithm: "RS256", PrivateKeyFile: writeRSAKey(t, dir, "old")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken := signTestToken(t, old)
+
+	current, err := NewKeyManager(KeyConfig{
+		Algorithm:      "EdDSA",
+		PrivateKeyFile: writeEd25519Key(t, dir, "current"),
+		PublicKeysDir:  filepath.Join(dir, "public"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jwt.Parse(oldToken, current.Keyfunc); err != nil {
+		t.Errorf("token signed before the rotation: %v", err)
+	}
+
+	if _, err := jwt.Parse(signTestToken(t, current), current.Keyfunc); err != nil {
+		t.Errorf("token signed after the rotation: %v", err)
+	}
+
+	// the current key is in the directory too, it is listed once
+	if set := current.JWKS(); len(set.Keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(set.Keys))
+	}
+
+	retired, err := NewKeyManager(KeyConfig{Algorithm: "EdDSA", PrivateKeyFile: filepath.Join(dir, "current.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jwt.Parse(oldToken, retired.Keyfunc); err == nil {
+		t.Error("token signed with a retired key still verifies")
+	}
+}
+
+func TestKeyfuncRejectsForeignTokens(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := NewKeyManager(KeyConfig{Algorithm: "RS256", PrivateKeyFile: writeRSAKey(t, dir, "rsa")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kid := keys.JWKS().Keys[0].Kid
+	publicPEM, err := os.ReadFile(filepath.Join(dir, "public", "rsa.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacKeys, err := NewKeyManager(KeyConfig{Algorithm: "HS256", SecretKey: []byte("other-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user"})
+	confused.Header["kid"] = kid
+	confusedToken, err := confused.SignedString(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user"})
+	unknown.Header["kid"] = "unknown"
+	unknownToken, err := unknown.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 signed with the public key", confusedToken},
+		{"unknown key id", unknownToken},
+		{"signed by another manager", signTestToken(t, hmacKeys)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := jwt.Parse(test.token, keys.Keyfunc); err == nil {
+				t.Error("token verified, want it rejected")
+			}
+		})
+	}
+}
+
+func TestNewKeyManagerErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaFile := writeRSAKey(t, dir, "rsa")
+	edFile := writeEd25519Key(t, dir, "ed25519")
+
+	garbage := filepath.Join(dir, "garbage")
+	if err := os.MkdirAll(garbage, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(garbage, "bad.pem"), []byte("not a key"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config KeyConfig
+	}{
+		{"HS256 without secret", KeyConfig{Algorithm: "HS256"}},
+		{"unsupported algorithm", KeyConfig{Algorithm: "HS512", SecretKey: []byte("secret")}},
+		{"RS256 without key file", KeyConfig{Algorithm: "RS256"}},
+		{"missing key file", KeyConfig{Algorithm: "RS256", PrivateKeyFile: filepath.Join(dir, "missing.pem")}},
+		{"RS256 with an Ed25519 key", KeyConfig{Algorithm: "RS256", PrivateKeyFile: edFile}},
+		{"EdDSA with an RSA key", KeyConfig{Algorithm: "EdDSA", PrivateKeyFile: rsaFile}},
+		{"unreadable public key", KeyConfig{Algorithm: "RS256", PrivateKeyFile: rsaFile, PublicKeysDir: garbage}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewKeyManager(test.config); err == nil {
+				t.Error("NewKeyManager succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrThrottleContention = errors.New("too many parallel login attempts")
+
+// reserveTries bounds how often Reserve re-reads a counter another attempt
+// changed under it.
+const reserveTries = 10
+
+type AttemptRecord struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// AttemptStore keeps login attempt counters. Increment is a compare-and-swap:
+// it only counts the attempt when the record is still the one seen, nil for
+// none, and reports whether it did. That way each slot goes to exactly one of
+// several parallel attempts.
+type AttemptStore interface {
+	Get(key string) (*AttemptRecord, error)
+	Increment(key string, seen *AttemptRecord, now time.Time, resetAfter time.Duration) (bool, error)
+	Decrement(key string) error
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type LoginThrottleConfig struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+}
+
+// LoginThrottle slows down password guessing. Every failure after the free
+// attempts doubles the wait, tracked both per account and per IP address, and
+// an account is locked outright once it reaches the lockout threshold.
+//
+// An attempt is counted as a failure before the password is checked, so a
+// burst of parallel guesses cannot all pass while none has failed yet. Callers
+// settle it afterwards with Failure, Release or Success.
+type LoginThrottle interface {
+	Reserve(email, ip string) (time.Duration, error)
+	Failure(email string) (bool, error)
+	Release(email, ip string) error
+	Success(email string) error
+	Unlock(email string) error
+}
+
+type implLoginThrottle struct {
+	store  AttemptStore
+	config LoginThrottleConfig
+}
+
+func NewLoginThrottle(store AttemptStore, config LoginThrottleConfig) LoginThrottle {
+	return &implLoginThrottle{
+		store:  store,
+		config: config,
+	}
+}
+
+// Reserve counts an attempt against the IP address and the account. When
+// either has to wait first it counts nothing and returns how long.
+func (t *implLoginThrottle) Reserve(email, ip string) (time.Duration, error) {
+	wait, err := t.reserve(ipKey(ip))
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+
+	wait, err = t.reserve(accountKey(email))
+	if err != nil || wait > 0 {
+		if err := t.store.Decrement(ipKey(ip)); err != nil {
+			return 0, err
+		}
+		return wait, err
+	}
+
+	return 0, nil
+}
+
+func (t *implLoginThrottle) reserve(key string) (time.Duration, error) {
+	for try := 0; try < reserveTries; try++ {
+		now := time.Now()
+
+		record, err := t.store.Get(key)
+		if err != nil {
+			return 0, err
+		}
+
+		if wait := t.wait(record, now); wait > 0 {
+			return wait, nil
+		}
+
+		counted, err := t.store.Increment(key, record, now, t.config.ResetAfter)
+		if err != nil || counted {
+			return 0, err
+		}
+	}
+
+	return 0, ErrThrottleContention
+}
+
+// wait returns how long the record blocks further attempts.
+func (t *implLoginThrottle) wait(record *AttemptRecord, now time.Time) time.Duration {
+	if record == nil || now.Sub(record.LastFailureAt) > t.config.ResetAfter {
+		return 0
+	}
+
+	var wait time.Duration
+
+	if record.LockedUntil != nil && record.LockedUntil.After(now) {
+		wait = record.LockedUntil.Sub(now)
+	}
+
+	if until := record.LastFailureAt.Add(t.delay(record.Failures)); until.After(now) {
+		wait = max(wait, until.Sub(now))
+	}
+
+	return wait
+}
+
+// Failure settles a reserved attempt as failed and reports whether it locked
+// the account.
+func (t *implLoginThrottle) Failure(email string) (bool, error) {
+	if t.config.LockoutThreshold <= 0 {
+		return false, nil
+	}
+
+	record, err := t.store.Get(accountKey(email))
+	if err != nil || record == nil || record.Failures < t.config.LockoutThreshold {
+		return false, err
+	}
+
+	if err := t.store.Lock(record.Key, time.Now().Add(t.config.LockoutDuration)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Release hands back a reserved attempt that did not fail, e.g. a right
+// password that still needs a second factor.
+func (t *implLoginThrottle) Release(email, ip string) error {
+	if err := t.store.Decrement(ipKey(ip)); err != nil {
+		return err
+	}
+	return t.store.Decrement(accountKey(email))
+}
+
+// Success forgets the account's failures. The IP counter is left alone so a
+// single known password cannot be used to reset it.
+func (t *implLoginThrottle) Success(email string) error {
+	return t.store.Reset(accountKey(email))
+}
+
+func (t *implLoginThrottle) Unlock(email string) error {
+	return t.store.Reset(accountKey(email))
+}
+
+func (t *implLoginThrottle) delay(failures int) time.Duration {
+	over := failures - t.config.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := 1; i < over && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, t.config.MaxDelay)
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
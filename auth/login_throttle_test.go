@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestThrottle() LoginThrottle {
+	return NewLoginThrottle(NewMemoryAttemptStore(), LoginThrottleConfig{
+		FreeAttempts:     3,
+		BaseDelay:        time.Minute,
+		MaxDelay:         time.Hour,
+		LockoutThreshold: 6,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       24 * time.Hour,
+	})
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := newTestThrottle().(*implLoginThrottle)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Minute},
+		{5, 2 * time.Minute},
+		{7, 8 * time.Minute},
+		{20, time.Hour},
+	}
+
+	for _, test := range tests {
+		if got := throttle.delay(test.failures); got != test.want {
+			t.Errorf("delay(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestLoginThrottleReserve(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts func(throttle LoginThrottle)
+		email    string
+		ip       string
+		wantWait bool
+	}{
+		{
+			name:     "first attempt",
+			attempts: func(LoginThrottle) {},
+			email:    "jane@example.com",
+			ip:       "10.0.0.1",
+		},
+		{
+			name: "free attempts used up",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 4; i++ {
+					throttle.Reserve("jane@example.com", "10.0.0.1")
+				}
+			},
+			email:    "jane@example.com",
+			ip:       "10.0.0.1",
+			wantWait: true,
+		},
+		{
+			name: "account counted across addresses",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 4; i++ {
+					throttle.Reserve("Jane@Example.com ", "10.0.0.1")
+				}
+			},
+			email:    "jane@example.com",
+			ip:       "10.0.0.2",
+			wantWait: true,
+		},
+		{
+			name: "address counted across accounts",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 4; i++ {
+					throttle.Reserve("other@example.com", "10.0.0.1")
+				}
+			},
+			email:    "jane@example.com",
+			ip:       "10.0.0.1",
+			wantWait: true,
+		},
+		{
+			name: "released attempts are not counted",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 10; i++ {
+					throttle.Reserve("jane@example.com", "10.0.0.1")
+					throttle.Release("jane@example.com", "10.0.0.1")
+				}
+			},
+			email: "jane@example.com",
+			ip:    "10.0.0.1",
+		},
+		{
+			name: "success forgets the account only",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 4; i++ {
+					throttle.Reserve("jane@example.com", "10.0.0.1")
+				}
+				throttle.Success("jane@example.com")
+			},
+			email:    "jane@example.com",
+			ip:       "10.0.0.1",
+			wantWait: true,
+		},
+		{
+			name: "success from another address",
+			attempts: func(throttle LoginThrottle) {
+				for i := 0; i < 4; i++ {
+					throttle.Reserve("jane@example.com", "10.0.0.1")
+				}
+				throttle.Success("jane@example.com")
+			},
+			email: "jane@example.com",
+			ip:    "10.0.0.2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttle := newTestThrottle()
+			test.attempts(throttle)
+
+			wait, err := throttle.Reserve(test.email, test.ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (wait > 0) != test.wantWait {
+				t.Errorf("wait = %s, want a wait: %v", wait, test.wantWait)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleBlockedAccountKeepsAddressFree(t *testing.T) {
+	throttle := newTestThrottle()
+
+	for i := 0; i < 4; i++ {
+		throttle.Reserve("jane@example.com", "10.0.0.1")
+	}
+
+	// attempts refused for the account must not use up the new address
+	for i := 0; i < 10; i++ {
+		if wait, _ := throttle.Reserve("jane@example.com", "10.0.0.2"); wait == 0 {
+			t.Fatal("blocked account was let through")
+		}
+	}
+
+	if wait, _ := throttle.Reserve("other@example.com", "10.0.0.2"); wait > 0 {
+		t.Errorf("address was throttled by refused attempts, wait = %s", wait)
+	}
+}
+
+func TestLoginThrottleFailureLocks(t *testing.T) {
+	throttle := NewLoginThrottle(NewMemoryAttemptStore(), LoginThrottleConfig{
+		FreeAttempts:     10,
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	})
+
+	for i := 1; i <= 3; i++ {
+		if _, err := throttle.Reserve("jane@example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+
+		locked, err := throttle.Failure("jane@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if locked != (i == 3) {
+			t.Fatalf("failure %d: locked = %v", i, locked)
+		}
+	}
+
+	if wait, _ := throttle.Reserve("jane@example.com", "10.0.0.2"); wait < 59*time.Minute {
+		t.Errorf("wait = %s, want the lockout", wait)
+	}
+
+	if err := throttle.Unlock("jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if wait, _ := throttle.Reserve("jane@example.com", "10.0.0.2"); wait > 0 {
+		t.Errorf("wait after unlock = %s, want none", wait)
+	}
+}
+
+// TestLoginThrottleParallelBurst sends many attempts at once: only the free
+// attempts may get through, however they interleave.
+func TestLoginThrottleParallelBurst(t *testing.T) {
+	throttle := newTestThrottle()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	passed := 0
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			wait, err := throttle.Reserve("jane@example.com", "10.0.0.1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if wait == 0 {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if passed != 4 {
+		t.Errorf("%d attempts got through, want 4", passed)
+	}
+}
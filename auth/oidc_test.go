@@ -0,0 +1,247 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"app/auth"
+	"app/auth/oidctest"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const redirectURL = "http://app.test/api/v1/user/oauth/mock/callback"
+
+func newOIDCClient(provider *oidctest.Provider) auth.OIDCProvider {
+	return auth.NewOIDCProvider(auth.OIDCProviderConfig{
+		Name:         "mock",
+		IssuerURL:    provider.URL,
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, nil)
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	client := newOIDCClient(provider)
+
+	authURL, err := client.AuthCodeURL(context.Background(), "the-state", "the-nonce", "the-challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             provider.ClientID,
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        "the-challenge",
+		"code_challenge_method": "S256",
+	}
+
+	for param, value := range want {
+		if got := parsed.Query().Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(p *oidctest.Provider)
+		verifier  func(verifier string) string
+		nonce     string
+		wantErr   bool
+		wantEmail string
+	}{
+		{
+			name:      "valid login",
+			wantEmail: "jane@example.com",
+		},
+		{
+			name:      "email from userinfo",
+			configure: func(p *oidctest.Provider) { p.OmitEmail = true },
+			wantEmail: "jane@example.com",
+		},
+		{
+			name:     "wrong code verifier",
+			verifier: func(string) string { return "not-the-verifier" },
+			wantErr:  true,
+		},
+		{
+			name:    "wrong nonce",
+			nonce:   "another-nonce",
+			wantErr: true,
+		},
+		{
+			name: "wrong audience",
+			configure: func(p *oidctest.Provider) {
+				p.ModifyIDToken = func(claims jwt.MapClaims) { claims["aud"] = "someone-else" }
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			configure: func(p *oidctest.Provider) {
+				p.ModifyIDToken = func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired id token",
+			configure: func(p *oidctest.Provider) {
+				p.ModifyIDToken = func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing subject",
+			configure: func(p *oidctest.Provider) {
+				p.ModifyIDToken = func(claims jwt.MapClaims) { delete(claims, "sub") }
+			},
+			wantErr: true,
+		},
+		{
+			name:      "forged signature",
+			configure: func(p *oidctest.Provider) { p.ForgeSignature = true },
+			wantErr:   true,
+		},
+		{
+			name: "userinfo for another subject",
+			configure: func(p *oidctest.Provider) {
+				p.OmitEmail = true
+				p.ModifyUserinfo = func(claims map[string]interface{}) { claims["sub"] = "someone-else" }
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := oidctest.NewProvider(t)
+			if test.configure != nil {
+				test.configure(provider)
+			}
+
+			client := newOIDCClient(provider)
+			ctx := context.Background()
+
+			verifier, challenge, err := auth.NewPKCEVerifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authURL, err := client.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, state, err := provider.Authorize(authURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if state != "the-state" {
+				t.Fatalf("state = %q, want %q", state, "the-state")
+			}
+
+			if test.verifier != nil {
+				verifier = test.verifier(verifier)
+			}
+
+			nonce := "the-nonce"
+			if test.nonce != "" {
+				nonce = test.nonce
+			}
+
+			identity, err := client.Exchange(ctx, code, verifier, nonce)
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Exchange succeeded, want an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if identity.Provider != "mock" || identity.Subject != provider.User.Subject {
+				t.Errorf("identity = %s/%s, want mock/%s", identity.Provider, identity.Subject, provider.User.Subject)
+			}
+
+			if identity.Email != test.wantEmail || !identity.EmailVerified {
+				t.Errorf("email = %q verified %v, want %q verified", identity.Email, identity.EmailVerified, test.wantEmail)
+			}
+		})
+	}
+}
+
+func TestOIDCCodeIsSingleUse(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	client := newOIDCClient(provider)
+	ctx := context.Background()
+
+	verifier, challenge, err := auth.NewPKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := client.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := provider.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Exchange(ctx, code, verifier, "the-nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Exchange(ctx, code, verifier, "the-nonce"); err == nil {
+		t.Fatal("second exchange of the same code succeeded")
+	}
+}
+
+func TestOIDCIDTokenErrors(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	provider.ModifyIDToken = func(claims jwt.MapClaims) { claims["aud"] = "someone-else" }
+
+	client := newOIDCClient(provider)
+	ctx := context.Background()
+
+	verifier, challenge, err := auth.NewPKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := client.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, err := provider.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Exchange(ctx, code, verifier, "the-nonce"); !errors.Is(err, auth.ErrOIDCIDToken) {
+		t.Fatalf("err = %v, want %v", err, auth.ErrOIDCIDToken)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type HasherConfig struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// implPasswordHasher writes new hashes with the configured algorithm but can
+// verify every supported format, so stored hashes keep working after the
+// algorithm or its parameters change. NeedsRehash tells callers when a hash
+// should be upgraded.
+type implPasswordHasher struct {
+	algorithm string
+	bcrypt    *bcryptHasher
+	argon2    *argon2Hasher
+}
+
+func NewPasswordHasher(config HasherConfig) (PasswordHasher, error) {
+	switch config.Algorithm {
+	case "argon2id", "bcrypt":
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", config.Algorithm)
+	}
+
+	if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if config.Argon2.Memory == 0 || config.Argon2.Iterations == 0 || config.Argon2.Parallelism == 0 {
+		return nil, errors.New("argon2 memory, iterations and parallelism must be positive")
+	}
+
+	return &implPasswordHasher{
+		algorithm: config.Algorithm,
+		bcrypt:    &bcryptHasher{cost: config.BcryptCost},
+		argon2:    &argon2Hasher{params: config.Argon2},
+	}, nil
+}
+
+func (h *implPasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == "bcrypt" {
+		return h.bcrypt.hash(password)
+	}
+	return h.argon2.hash(password)
+}
+
+func (h *implPasswordHasher) Verify(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.argon2.verify(password, encoded)
+	case strings.HasPrefix(encoded, "$2"):
+		return h.bcrypt.verify(password, encoded)
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func (h *implPasswordHasher) NeedsRehash(encoded string) bool {
+	if h.algorithm == "bcrypt" {
+		return h.bcrypt.needsRehash(encoded)
+	}
+	return h.argon2.needsRehash(encoded)
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (h *bcryptHasher) hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *bcryptHasher) verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *bcryptHasher) needsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+type argon2Hasher struct {
+	params Argon2Params
+}
+
+// hash returns a PHC string: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+func (h *argon2Hasher) hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2Hasher) verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (h *argon2Hasher) needsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	// argon2 panics on zero iterations or threads
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	// an empty key would match every password
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// small parameters keep the tests fast
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T, algorithm string, cost int, params Argon2Params) PasswordHasher {
+	t.Helper()
+
+	hasher, err := NewPasswordHasher(HasherConfig{Algorithm: algorithm, BcryptCost: cost, Argon2: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hasher
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon2 := newTestHasher(t, "argon2id", 4, testArgon2)
+	bcrypt := newTestHasher(t, "bcrypt", 4, testArgon2)
+
+	argon2Hash, err := argon2.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("argon2 hash = %s, want a PHC string with the configured parameters", argon2Hash)
+	}
+
+	tests := []struct {
+		name     string
+		hasher   PasswordHasher
+		password string
+		encoded  string
+		want     bool
+	}{
+		{"argon2 hash", argon2, "correct horse", argon2Hash, true},
+		{"argon2 hash, wrong password", argon2, "wrong horse", argon2Hash, false},
+		{"bcrypt hash", bcrypt, "correct horse", bcryptHash, true},
+		{"bcrypt hash, wrong password", bcrypt, "wrong horse", bcryptHash, false},
+		{"bcrypt hash read by the argon2 hasher", argon2, "correct horse", bcryptHash, true},
+		{"argon2 hash read by the bcrypt hasher", bcrypt, "correct horse", argon2Hash, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.hasher.Verify(test.password, test.encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok != test.want {
+				t.Errorf("Verify = %v, want %v", ok, test.want)
+			}
+		})
+	}
+}
+
+func TestPasswordHasherRejectsMalformedHashes(t *testing.T) {
+	hasher := newTestHasher(t, "argon2id", 4, testArgon2)
+
+	salt := "c29tZXNhbHRzb21lc2FsdA"
+	key := "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"plain text", "correct horse"},
+		{"argon2i", "$argon2i$v=19$m=1024,t=1,p=1$" + salt + "$" + key},
+		{"other version", "$argon2id$v=16$m=1024,t=1,p=1$" + salt + "$" + key},
+		{"missing parameters", "$argon2id$v=19$m=1024$" + salt + "$" + key},
+		{"zero iterations", "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key},
+		{"zero threads", "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key},
+		{"zero memory", "$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key},
+		{"empty salt", "$argon2id$v=19$m=1024,t=1,p=1$$" + key},
+		{"empty key", "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$"},
+		{"salt not base64", "$argon2id$v=19$m=1024,t=1,p=1$!!!$" + key},
+		{"too many parts", "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$" + key + "$extra"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := hasher.Verify("correct horse", test.encoded)
+			if ok || !errors.Is(err, ErrUnknownHashFormat) {
+				t.Errorf("Verify = %v, %v, want false, %v", ok, err, ErrUnknownHashFormat)
+			}
+
+			if !hasher.NeedsRehash(test.encoded) {
+				t.Error("NeedsRehash = false, want true")
+			}
+		})
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	current := newTestHasher(t, "argon2id", 4, testArgon2)
+
+	stronger := testArgon2
+	stronger.Iterations = 2
+
+	longerKey := testArgon2
+	longerKey.KeyLength = 64
+
+	hashWith := func(hasher PasswordHasher) string {
+		encoded, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{"same argon2 parameters", current, hashWith(current), false},
+		{"other argon2 iterations", newTestHasher(t, "argon2id", 4, stronger), hashWith(current), true},
+		{"other argon2 key length", newTestHasher(t, "argon2id", 4, longerKey), hashWith(current), true},
+		{"bcrypt hash after switching to argon2", current, hashWith(newTestHasher(t, "bcrypt", 4, testArgon2)), true},
+		{"argon2 hash after switching to bcrypt", newTestHasher(t, "bcrypt", 4, testArgon2), hashWith(current), true},
+		{"same bcrypt cost", newTestHasher(t, "bcrypt", 4, testArgon2), hashWith(newTestHasher(t, "bcrypt", 4, testArgon2)), false},
+		{"other bcrypt cost", newTestHasher(t, "bcrypt", 5, testArgon2), hashWith(newTestHasher(t, "bcrypt", 4, testArgon2)), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.hasher.NeedsRehash(test.encoded); got != test.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HasherConfig
+	}{
+		{"unknown algorithm", HasherConfig{Algorithm: "md5", BcryptCost: 10, Argon2: testArgon2}},
+		{"bcrypt cost too low", HasherConfig{Algorithm: "bcrypt", BcryptCost: 3, Argon2: testArgon2}},
+		{"bcrypt cost too high", HasherConfig{Algorithm: "bcrypt", BcryptCost: 32, Argon2: testArgon2}},
+		{"zero argon2 memory", HasherConfig{Algorithm: "argon2id", BcryptCost: 10, Argon2: Argon2Params{Iterations: 1, Parallelism: 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewPasswordHasher(test.config); err == nil {
+				t.Error("NewPasswordHasher succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = loadCommonPasswords(commonPasswordList)
+
+// BcryptMaxBytes is the longest password bcrypt hashes.
+const BcryptMaxBytes = 72
+
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	RejectCommon  bool
+
+	// MaxBytes limits the encoded length on top of MaxLength, which counts
+	// characters. Set it to BcryptMaxBytes when hashing with bcrypt.
+	MaxBytes int
+}
+
+// Validate returns one message per rule the password breaks, or nil when it
+// satisfies the policy.
+func (p PasswordPolicy) Validate(password string) []string {
+	violations := make([]string, 0)
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d characters long", p.MaxLength))
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long, accented and non-Latin characters take more than one", p.MaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "password must contain an uppercase letter")
+	}
+
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "password must contain a lowercase letter")
+	}
+
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "password must contain a digit")
+	}
+
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "password must contain a symbol")
+	}
+
+	if p.RejectCommon && commonPasswords[strings.ToLower(password)] {
+		violations = append(violations, "password is too common")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
+
+func loadCommonPasswords(list string) map[string]bool {
+	passwords := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+
+	return passwords
+}
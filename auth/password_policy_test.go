@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     64,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		RejectCommon:  true,
+	}
+
+	bcrypt := PasswordPolicy{MinLength: 8, MaxLength: 64, MaxBytes: BcryptMaxBytes}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"meets every rule", strict, "Correct-Horse-7", nil},
+		{"too short", strict, "Ab1!", []string{"password must be at least 8 characters long"}},
+		{"too long", strict, "Aa1!" + strings.Repeat("x", 61), []string{"password must be at most 64 characters long"}},
+		{"characters, not bytes", strict, "Ab1!ééééé", nil},
+		{"missing classes", strict, "abcdefghij", []string{
+			"password must contain an uppercase letter",
+			"password must contain a digit",
+			"password must contain a symbol",
+		}},
+		{"common password", PasswordPolicy{MinLength: 8, RejectCommon: true}, "Password", []string{"password is too common"}},
+		{"bcrypt within 72 bytes", bcrypt, strings.Repeat("é", 36), nil},
+		{"bcrypt over 72 bytes", bcrypt, strings.Repeat("é", 37), []string{
+			"password must be at most 72 bytes long, accented and non-Latin characters take more than one",
+		}},
+		{"bcrypt over both limits", bcrypt, strings.Repeat("é", 65), []string{"password must be at most 64 characters long"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.Validate(test.password); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Validate = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PermissionResolver maps the role names carried in an access token to the
+// permissions they grant. The role table changes rarely, so the mapping is
+// cached for a short while instead of being queried on every request.
+type PermissionResolver interface {
+	Permissions(roles []string) (map[string]bool, error)
+}
+
+type implPermissionResolver struct {
+	db       *gorm.DB
+	ttl      time.Duration
+	mu       sync.Mutex
+	loadedAt time.Time
+	byRole   map[string][]string
+}
+
+func NewPermissionResolver(db *gorm.DB, ttl time.Duration) PermissionResolver {
+	return &implPermissionResolver{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+type rolePermission struct {
+	RoleName       string
+	PermissionName string
+}
+
+func (r *implPermissionResolver) Permissions(roles []string) (map[string]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byRole == nil || time.Since(r.loadedAt) > r.ttl {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		for _, permission := range r.byRole[role] {
+			granted[permission] = true
+		}
+	}
+
+	return granted, nil
+}
+
+func (r *implPermissionResolver) load() error {
+	rows := make([]rolePermission, 0)
+
+	err := r.db.Table("roles").
+		Select("roles.name AS role_name, permissions.name AS permission_name").
+		Joins("JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("roles.deleted_at IS NULL AND permissions.deleted_at IS NULL").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	byRole := make(map[string][]string)
+	for _, row := range rows {
+		byRole[row.RoleName] = append(byRole[row.RoleName], row.PermissionName)
+	}
+
+	r.byRole = byRole
+	r.loadedAt = time.Now()
+
+	return nil
+}
+
+// RoleNames returns the names of the roles assigned to the user.
+func RoleNames(db *gorm.DB, userID string) ([]string, error) {
+	roles := make([]string, 0)
+
+	err := db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.deleted_at IS NULL", userID).
+		Pluck("roles.name", &roles).Error
+
+	return roles, err
+}
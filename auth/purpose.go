@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	PurposeEmailVerification = "email-verification"
+	PurposeMFAChallenge      = "mfa-challenge"
+	PurposeOIDCState         = "oidc-state"
+	PurposeEmailChange       = "email-change"
+)
+
+type PurposeClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email,omitempty"`
+
+	// used by the OIDC state cookie
+	Provider     string `json:"provider,omitempty"`
+	Nonce        string `json:"nonce,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+}
+
+// IssuePurposeToken signs a short-lived token that is only good for one step,
+// such as following an email verification link.
+func IssuePurposeToken(keys KeyManager, purpose string, claims *PurposeClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	return keys.Sign(purpose+"+jwt", claims)
+}
+
+func ParsePurposeToken(keys KeyManager, purpose, tokenString string) (*PurposeClaims, error) {
+	claims := &PurposeClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrTokenInvalid
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ != purpose+"+jwt" || claims.Subject == "" {
+		return nil, ErrTokenInvalid
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore decides whether an otherwise valid access token may still be
+// used. Tokens are revoked either one by one through their ID or all at once
+// for a user, in which case every token issued before the cut-off is rejected.
+type RevocationStore interface {
+	RevokeToken(tokenID string, expiresAt time.Time) error
+	RevokeUser(userID string, at time.Time) error
+	IsRevoked(tokenID, userID string, issuedAt time.Time) (bool, error)
+}
+
+type implMemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]time.Time
+}
+
+func NewMemoryRevocationStore() RevocationStore {
+	return &implMemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]time.Time),
+	}
+}
+
+func (s *implMemoryRevocationStore) RevokeToken(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.tokens {
+		if now.After(exp) {
+			delete(s.tokens, id)
+		}
+	}
+
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *implMemoryRevocationStore) RevokeUser(userID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID] = at
+	return nil
+}
+
+func (s *implMemoryRevocationStore) IsRevoked(tokenID, userID string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[tokenID]; ok {
+		return true, nil
+	}
+
+	if revokedAt, ok := s.users[userID]; ok && issuedBefore(issuedAt, revokedAt) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+type implPostgresRevocationStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresRevocationStore(db *gorm.DB) RevocationStore {
+	return &implPostgresRevocationStore{
+		db: db,
+	}
+}
+
+func (s *implPostgresRevocationStore) RevokeToken(tokenID string, expiresAt time.Time) error {
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&model.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	token := &model.RevokedToken{
+		TokenID:   tokenID,
+		ExpiresAt: expiresAt,
+	}
+
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (s *implPostgresRevocationStore) RevokeUser(userID string, at time.Time) error {
+	revocation := &model.UserRevocation{
+		UserID:    userID,
+		RevokedAt: at,
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+	}).Create(revocation).Error
+}
+
+func (s *implPostgresRevocationStore) IsRevoked(tokenID, userID string, issuedAt time.Time) (bool, error) {
+	var count int64
+
+	if err := s.db.Model(&model.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	if count > 0 {
+		return true, nil
+	}
+
+	revocation := &model.UserRevocation{}
+
+	if err := s.db.Where("user_id = ?", userID).First(revocation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return issuedBefore(issuedAt, revocation.RevokedAt), nil
+}
+
+// issuedBefore compares at second precision because that is all the iat claim
+// carries. A token from the same second as the revocation may predate it, so
+// it counts as revoked. So does a token issued later within that second, e.g.
+// by signing in again right after logging out everywhere, and it stays refused
+// for its whole lifetime rather than for the rest of the second. Refreshing
+// once the second has passed issues a usable one.
+func issuedBefore(issuedAt, revokedAt time.Time) bool {
+	return issuedAt.Unix() <= revokedAt.Unix()
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"app/migrations/dbtest"
+)
+
+const (
+	revokedUser = "6f1c2a4e-8d0b-4c55-9a57-2f0e4b7d9c11"
+	otherUser   = "0b9e7d3a-51c2-4f86-8e2d-7c4a1f5b3e22"
+)
+
+func TestMemoryRevocationStore(t *testing.T) {
+	testRevocationStore(t, NewMemoryRevocationStore())
+}
+
+func TestPostgresRevocationStore(t *testing.T) {
+	testRevocationStore(t, NewPostgresRevocationStore(dbtest.New(t)))
+}
+
+func testRevocationStore(t *testing.T, store RevocationStore) {
+	revokedAt := time.Date(2024, 5, 1, 12, 0, 0, 400*int(time.Millisecond), time.UTC)
+
+	if err := store.RevokeToken("revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// revoking twice is not an error
+	if err := store.RevokeToken("revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUser(revokedUser, revokedAt.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// a later revocation moves the cut-off
+	if err := store.RevokeUser(revokedUser, revokedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	// iat only carries whole seconds
+	second := revokedAt.Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		tokenID  string
+		userID   string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"revoked token", "revoked", otherUser, second.Add(time.Hour), true},
+		{"issued before the revocation", "a", revokedUser, second.Add(-time.Second), true},
+		{"issued in the same second", "b", revokedUser, second, true},
+		{"issued in the next second", "c", revokedUser, second.Add(time.Second), false},
+		{"other user", "d", otherUser, second.Add(-time.Hour), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revoked, err := store.IsRevoked(test.tokenID, test.userID, test.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if revoked != test.want {
+				t.Errorf("IsRevoked = %v, want %v", revoked, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a sealed value and the format it was sealed in.
+const sealedPrefix = "v1:"
+
+var ErrSecretUnreadable = errors.New("secret cannot be decrypted")
+
+// SecretBox encrypts secrets the app has to read back, such as TOTP seeds,
+// before they are stored, so a leaked database dump does not give them away.
+type SecretBox interface {
+	Seal(plaintext string) (string, error)
+	// Open decrypts a sealed value. Values stored before encryption was
+	// introduced are returned as they are.
+	Open(value string) (string, error)
+}
+
+type implSecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox seals with AES-256-GCM under a 32 byte key.
+func NewSecretBox(key []byte) (SecretBox, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secret box key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &implSecretBox{aead: aead}, nil
+}
+
+func (b *implSecretBox) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *implSecretBox) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", ErrSecretUnreadable
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSecretUnreadable
+	}
+
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether a stored value was sealed, as opposed to written
+// in plain text before encryption was introduced.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewSecretBox(bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sealed == again {
+		t.Error("sealing twice gave the same value, want a fresh nonce each time")
+	}
+
+	// flip a bit of the tag itself, the last base64 character may only
+	// carry padding bits the decoder ignores
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := sealedPrefix + base64.RawStdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name    string
+		box     SecretBox
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"sealed value", box, sealed, "JBSWY3DPEHPK3PXP", false},
+		{"plain value from before encryption", box, "JBSWY3DPEHPK3PXP", "JBSWY3DPEHPK3PXP", false},
+		{"empty value", box, "", "", false},
+		{"wrong key", other, sealed, "", true},
+		{"tampered value", box, tampered, "", true},
+		{"truncated value", box, sealedPrefix + "AAAA", "", true},
+		{"not base64", box, sealedPrefix + "!!!", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.box.Open(test.value)
+
+			if test.wantErr {
+				if !errors.Is(err, ErrSecretUnreadable) {
+					t.Fatalf("err = %v, want %v", err, ErrSecretUnreadable)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want {
+				t.Errorf("Open = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewSecretBoxKeyLength(t *testing.T) {
+	for _, size := range []int{0, 16, 31, 33} {
+		if _, err := NewSecretBox(make([]byte, size)); err == nil {
+			t.Errorf("NewSecretBox accepted a %d byte key", size)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"app/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrAccountSuspended    = errors.New("account is suspended")
+)
+
+type SessionConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Claims          ClaimsConfig
+}
+
+type SessionMeta struct {
+	UserAgent string
+	IPAddress string
+}
+
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type SessionManager interface {
+	Create(user *model.User, meta SessionMeta) (*TokenPair, error)
+	Rotate(refreshToken string, meta SessionMeta) (*TokenPair, error)
+	Revoke(sessionID string) error
+	RevokeAll(userID string) error
+}
+
+type implSessionManager struct {
+	db     *gorm.DB
+	keys   KeyManager
+	config SessionConfig
+}
+
+func NewSessionManager(db *gorm.DB, keys KeyManager, config SessionConfig) SessionManager {
+	return &implSessionManager{
+		db:     db,
+		keys:   keys,
+		config: config,
+	}
+}
+
+// Create starts a new token family for the user.
+func (m *implSessionManager) Create(user *model.User, meta SessionMeta) (*TokenPair, error) {
+	var pair *TokenPair
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pair, err = m.issue(tx, user, uuid.NewString(), meta)
+		return err
+	})
+
+	return pair, err
+}
+
+// Rotate exchanges a refresh token for a new pair. Presenting a token that has
+// already been rotated or revoked revokes every session in its family. The
+// tokens of a suspended user are not rotated.
+func (m *implSessionManager) Rotate(refreshToken string, meta SessionMeta) (*TokenPair, error) {
+	var pair *TokenPair
+	var reusedFamily string
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		session := &model.Session{}
+
+		if err := tx.Where("token_hash = ?", HashToken(refreshToken)).First(session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		now := time.Now()
+
+		if session.RotatedAt != nil || session.RevokedAt != nil {
+			reusedFamily = session.FamilyID
+			return nil
+		}
+
+		if now.After(session.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		result := tx.Model(&model.Session{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", session.ID).
+			Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// another request rotated this token between our read and write
+		if result.RowsAffected == 0 {
+			reusedFamily = session.FamilyID
+			return nil
+		}
+
+		// reload the user so role changes and suspension reach the next access token
+		user := &model.User{}
+		if err := tx.Select("id", "role", "suspended_at").Where("id = ?", session.UserID).First(user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if user.SuspendedAt != nil {
+			return ErrAccountSuspended
+		}
+
+		var err error
+		pair, err = m.issue(tx, user, session.FamilyID, meta)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if reusedFamily != "" {
+		if err := m.revokeFamily(reusedFamily); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	return pair, nil
+}
+
+// Revoke ends the token family the given session belongs to.
+func (m *implSessionManager) Revoke(sessionID string) error {
+	session := &model.Session{}
+
+	if err := m.db.Select("family_id").Where("id = ?", sessionID).First(session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return m.revokeFamily(session.FamilyID)
+}
+
+func (m *implSessionManager) RevokeAll(userID string) error {
+	return m.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (m *implSessionManager) revokeFamily(familyID string) error {
+	return m.db.Model(&model.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (m *implSessionManager) issue(tx *gorm.DB, user *model.User, familyID string, meta SessionMeta) (*TokenPair, error) {
+	now := time.Now()
+
+	refreshToken, err := RandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &model.Session{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(refreshToken),
+		UserAgent: truncate(meta.UserAgent, 255),
+		IPAddress: meta.IPAddress,
+		ExpiresAt: now.Add(m.config.RefreshTokenTTL),
+	}
+
+	if err := tx.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	roles, err := RoleNames(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{
+		RegisteredClaims: m.config.Claims.registered(user.ID, now, m.config.AccessTokenTTL),
+		Role:             user.Role,
+		Roles:            roles,
+		SessionID:        session.ID,
+	}
+	claims.ID = uuid.NewString()
+
+	accessToken, err := m.keys.Sign(TokenTypeAccess, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  claims.ExpiresAt.Time,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"app/migrations/dbtest"
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+func newTestSessions(t *testing.T) (*gorm.DB, SessionManager, *model.User) {
+	t.Helper()
+
+	db := dbtest.New(t)
+
+	keys, err := NewKeyManager(KeyConfig{Algorithm: "HS256", SecretKey: []byte("test-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &model.User{Username: "sessions", Email: "sessions@example.com", Password: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db, NewSessionManager(db, keys, SessionConfig{
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}), user
+}
+
+func TestSessionRotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string
+		want    error
+	}{
+		{
+			name: "current token",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				return pair.RefreshToken
+			},
+		},
+		{
+			name: "unknown token",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				return "not-a-refresh-token"
+			},
+			want: ErrInvalidRefreshToken,
+		},
+		{
+			name: "expired token",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				db.Model(&model.Session{}).Where("token_hash = ?", HashToken(pair.RefreshToken)).Update("expires_at", time.Now().Add(-time.Minute))
+				return pair.RefreshToken
+			},
+			want: ErrInvalidRefreshToken,
+		},
+		{
+			name: "rotated token",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				if _, err := sessions.Rotate(pair.RefreshToken, SessionMeta{}); err != nil {
+					t.Fatal(err)
+				}
+				return pair.RefreshToken
+			},
+			want: ErrRefreshTokenReused,
+		},
+		{
+			name: "revoked token",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				session := &model.Session{}
+				db.Where("token_hash = ?", HashToken(pair.RefreshToken)).First(session)
+				if err := sessions.Revoke(session.ID); err != nil {
+					t.Fatal(err)
+				}
+				return pair.RefreshToken
+			},
+			want: ErrRefreshTokenReused,
+		},
+		{
+			name: "suspended user",
+			prepare: func(t *testing.T, db *gorm.DB, sessions SessionManager, pair *TokenPair) string {
+				db.Model(&model.User{}).Where("email = ?", "sessions@example.com").Update("suspended_at", time.Now())
+				return pair.RefreshToken
+			},
+			want: ErrAccountSuspended,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, sessions, user := newTestSessions(t)
+
+			pair, err := sessions.Create(user, SessionMeta{UserAgent: "test", IPAddress: "10.0.0.1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rotated, err := sessions.Rotate(test.prepare(t, db, sessions, pair), SessionMeta{})
+
+			if !errors.Is(err, test.want) {
+				t.Fatalf("err = %v, want %v", err, test.want)
+			}
+
+			if test.want == nil && (rotated.RefreshToken == pair.RefreshToken || rotated.AccessToken == "") {
+				t.Errorf("rotation returned %+v, want a new pair", rotated)
+			}
+		})
+	}
+}
+
+// TestSessionReuseRevokesFamily replays a rotated token: the thief's and the
+// owner's newer tokens both stop working, other logins do not.
+func TestSessionReuseRevokesFamily(t *testing.T) {
+	_, sessions, user := newTestSessions(t)
+
+	first, err := sessions.Create(user, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := sessions.Create(user, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := sessions.Rotate(first.RefreshToken, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sessions.Rotate(first.RefreshToken, SessionMeta{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, err := sessions.Rotate(second.RefreshToken, SessionMeta{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("newer token of the family: err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, err := sessions.Rotate(other.RefreshToken, SessionMeta{}); err != nil {
+		t.Errorf("other login: err = %v, want none", err)
+	}
+}
+
+func TestSessionRevokeAll(t *testing.T) {
+	_, sessions, user := newTestSessions(t)
+
+	pairs := make([]*TokenPair, 0, 3)
+	for i := 0; i < 3; i++ {
+		pair, err := sessions.Create(user, SessionMeta{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if err := sessions.RevokeAll(user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, pair := range pairs {
+		if _, err := sessions.Rotate(pair.RefreshToken, SessionMeta{}); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("session %d: err = %v, want %v", i, err, ErrRefreshTokenReused)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the SHA1 secret from RFC 6238 appendix B
+var rfcSecret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// the RFC lists 8 digit codes; ours are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		if got := totpCode([]byte("12345678901234567890"), uint64(test.unix/totpPeriod)); got != test.want {
+			t.Errorf("code at %d = %s, want %s", test.unix, got, test.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current code", rfcSecret, "050471", step, true},
+		{"lowercase secret", strings.ToLower(rfcSecret), "050471", step, true},
+		{"surrounding spaces", rfcSecret, " 050471 ", step, true},
+		{"previous step", rfcSecret, totpCode([]byte("12345678901234567890"), uint64(step-1)), step - 1, true},
+		{"next step", rfcSecret, totpCode([]byte("12345678901234567890"), uint64(step+1)), step + 1, true},
+		{"two steps old", rfcSecret, totpCode([]byte("12345678901234567890"), uint64(step-2)), 0, false},
+		{"wrong code", rfcSecret, "123456", 0, false},
+		{"too short", rfcSecret, "05047", 0, false},
+		{"invalid secret", "not base32!", "050471", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(test.secret, test.code, now)
+			if ok != test.wantOK || gotStep != test.wantStep {
+				t.Errorf("ValidateTOTP = %d, %v, want %d, %v", gotStep, ok, test.wantStep, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q does not decode to 20 bytes", secret)
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Go Fiber BoilerPlate", "jane@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/Go Fiber BoilerPlate:jane@example.com" {
+		t.Errorf("uri = %s, want otpauth://totp/<issuer>:<account>", uri)
+	}
+
+	if strings.Contains(uri, "+") {
+		t.Errorf("uri = %s, want spaces encoded as %%20", uri)
+	}
+
+	if parsed.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || parsed.Query().Get("issuer") != "Go Fiber BoilerPlate" {
+		t.Errorf("uri = %s, want the secret and issuer in the query", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || NormalizeRecoveryCode(code) != code {
+			t.Errorf("code %q is not in the form xxxxx-xxxxx", code)
+		}
+		seen[code] = true
+	}
+
+	if len(seen) != 10 {
+		t.Errorf("got %d distinct codes, want 10", len(seen))
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"abcde-fghij", "abcde-fghij"},
+		{"ABCDE-FGHIJ", "abcde-fghij"},
+		{"abcdefghij", "abcde-fghij"},
+		{" abcde fghij ", "abcde-fghij"},
+		{"abc", "abc"},
+	}
+
+	for _, test := range tests {
+		if got := NormalizeRecoveryCode(test.input); got != test.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
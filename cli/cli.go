@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"serve":        {"start the HTTP server", serve},
+	"migrate":      {"manage the database schema, see migrate -h", migrate},
+	"seed":         {"load fixture data from a YAML or JSON file", seed},
+	"create-admin": {"create an admin user from flags or prompts", createAdmin},
+	"routes":       {"print every route with its middleware chain", routes},
+}
+
+var order = []string{"serve", "migrate", "seed", "create-admin", "routes"}
+
+// Run executes the subcommand named by args[0]. Without arguments the server
+// is started, so plain `go run .` keeps working.
+func Run(args []string) error {
+	if len(args) == 0 {
+		return serve(nil)
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage()
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	return cmd.run(args[1:])
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: app <command> [arguments]\n\ncommands:")
+	for _, name := range order {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+var errUsage = errors.New("invalid arguments")
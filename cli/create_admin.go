@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"app/config"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/term"
+	"gorm.io/gorm"
+)
+
+type adminInput struct {
+	Username    string `validate:"required,min=5,max=30"`
+	Email       string `validate:"required,email,max=50"`
+	PhoneNumber string `validate:"max=20"`
+	Password    string `validate:"required"`
+}
+
+// createAdmin creates a verified user with the admin role. Values missing from
+// the flags are prompted for when stdin is a terminal.
+func createAdmin(args []string) error {
+	input := &adminInput{}
+
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	flags.StringVar(&input.Username, "username", "", "username")
+	flags.StringVar(&input.Email, "email", "", "email address")
+	flags.StringVar(&input.PhoneNumber, "phone", "", "phone number")
+	flags.StringVar(&input.Password, "password", "", "password, prompted for when omitted")
+	settings := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(settings)
+	if err != nil {
+		return err
+	}
+
+	if err := promptAdminInput(input); err != nil {
+		return err
+	}
+
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+
+	if err := validator.New().Struct(input); err != nil {
+		return fmt.Errorf("invalid admin details: %w", err)
+	}
+
+	if violations := config.NewPasswordPolicy(cfg.Auth.Password).Validate(input.Password); violations != nil {
+		return fmt.Errorf("password does not meet the policy: %s", strings.Join(violations, ", "))
+	}
+
+	hashed, err := config.NewPasswordHasher(cfg.Auth.Password).Hash(input.Password)
+	if err != nil {
+		return err
+	}
+
+	db := config.ConnectDB(cfg)
+	defer config.CloseDB(db)
+
+	if err := config.SeedRoles(db); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	user := &model.User{
+		Username:        input.Username,
+		Email:           input.Email,
+		PhoneNumber:     input.PhoneNumber,
+		Password:        hashed,
+		Role:            model.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.User{}).Unscoped().Where("username = ? OR email = ?", user.Username, user.Email).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errors.New("a user with this username or email already exists")
+		}
+
+		role := &model.Role{}
+		if err := tx.Where("name = ?", "admin").First(role).Error; err != nil {
+			return err
+		}
+
+		user.Roles = []model.Role{*role}
+
+		return tx.Omit("Roles.*").Create(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created admin %s (%s)\n", user.Username, user.ID)
+
+	return nil
+}
+
+func promptAdminInput(input *adminInput) error {
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+
+	if !interactive {
+		if input.Username == "" || input.Email == "" || input.Password == "" {
+			return errors.New("-username, -email and -password are required when stdin is not a terminal")
+		}
+		return nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	for _, field := range []struct {
+		label string
+		value *string
+	}{
+		{"Username", &input.Username},
+		{"Email", &input.Email},
+		{"Phone number (optional)", &input.PhoneNumber},
+	} {
+		if *field.value != "" {
+			continue
+		}
+
+		fmt.Printf("%s: ", field.label)
+
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		*field.value = strings.TrimSpace(line)
+	}
+
+	if input.Password != "" {
+		return nil
+	}
+
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := readPassword("Confirm password: ")
+	if err != nil {
+		return err
+	}
+
+	if password != confirmation {
+		return errors.New("passwords do not match")
+	}
+
+	input.Password = password
+
+	return nil
+}
+
+func readPassword(prompt string) (string, error) {
+	fmt.Print(prompt)
+	defer fmt.Println()
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"app/config"
+)
+
+const migrateUsage = `usage: app migrate [flags] <command>
+
+commands:
+  up [n]     apply pending migrations, all of them unless n is given
+  down [n]   roll back the last n migrations, 1 by default
+  redo       roll back the last migration and apply it again
+  status     list migrations and when they were applied`
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, migrateUsage+"\n\nflags:")
+		flags.PrintDefaults()
+	}
+	settings := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps %q", args[1])
+		}
+		steps = n
+	}
+
+	cfg, err := config.Load(settings)
+	if err != nil {
+		return err
+	}
+
+	db := config.ConnectDB(cfg)
+	defer config.CloseDB(db)
+
+	migrator := config.NewMigrator(db)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(steps)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+
+		return config.SeedRoles(db)
+	case "down":
+		_, err := migrator.Down(steps)
+		return err
+	case "redo":
+		_, err := migrator.Redo()
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Missing {
+				appliedAt += " (file missing)"
+			}
+
+			fmt.Fprintf(writer, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return writer.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
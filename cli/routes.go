@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"app/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+func routes(args []string) error {
+	flags := flag.NewFlagSet("routes", flag.ContinueOnError)
+	method := flags.String("method", "", "only list routes for this HTTP method")
+	settings := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadOffline(settings)
+	if err != nil {
+		return err
+	}
+
+	offline := config.NewOfflineDB()
+
+	app := config.NewApp(cfg, offline, offline)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "METHOD\tPATH\tHANDLERS")
+
+	for _, route := range routeChains(app) {
+		if *method != "" && !strings.EqualFold(route.method, *method) {
+			continue
+		}
+		if route.method == fiber.MethodHead {
+			continue
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", route.method, route.path, strings.Join(route.handlers, " -> "))
+	}
+
+	return writer.Flush()
+}
+
+type routeChain struct {
+	method   string
+	path     string
+	handlers []string
+}
+
+// routeChains lists each route with the handlers a request to it runs through:
+// the app and group middleware registered before it whose prefix matches,
+// followed by its own handlers.
+func routeChains(app *fiber.App) []routeChain {
+	// Use routes are not marked in the public Route, so the non-use routes are
+	// recognised by their handler slices
+	endpoints := map[*fiber.Handler]bool{}
+	for _, route := range app.GetRoutes(true) {
+		endpoints[&route.Handlers[0]] = true
+	}
+
+	chains := make([]routeChain, 0)
+
+	for _, stack := range app.Stack() {
+		middleware := make([]*fiber.Route, 0)
+
+		for _, route := range stack {
+			if !endpoints[&route.Handlers[0]] {
+				middleware = append(middleware, route)
+				continue
+			}
+
+			chain := routeChain{method: route.Method, path: route.Path}
+
+			for _, use := range middleware {
+				if matchesPrefix(use.Path, route.Path) {
+					chain.handlers = append(chain.handlers, handlerNames(use.Handlers)...)
+				}
+			}
+			chain.handlers = append(chain.handlers, handlerNames(route.Handlers)...)
+
+			chains = append(chains, chain)
+		}
+	}
+
+	return chains
+}
+
+func matchesPrefix(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// handlerNames turns "app/middleware.(*implMiddleware).RequirePermission.func1"
+// into "middleware.RequirePermission".
+func handlerNames(handlers []fiber.Handler) []string {
+	names := make([]string, 0, len(handlers))
+
+	for _, handler := range handlers {
+		name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+
+		name = name[strings.LastIndex(name, "/")+1:]
+		name = strings.TrimSuffix(name, "-fm")
+		name = closureSuffix.ReplaceAllString(name, "")
+
+		if start := strings.Index(name, "(*"); start >= 0 {
+			if end := strings.Index(name[start:], ")."); end >= 0 {
+				name = name[:start] + name[start+end+2:]
+			}
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"app/auth"
+	"app/config"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+type fixtures struct {
+	Roles      []roleFixture     `json:"roles" yaml:"roles" validate:"dive"`
+	Users      []userFixture     `json:"users" yaml:"users" validate:"dive"`
+	Categories []categoryFixture `json:"categories" yaml:"categories" validate:"dive"`
+	Products   []productFixture  `json:"products" yaml:"products" validate:"dive"`
+}
+
+type roleFixture struct {
+	Name        string   `json:"name" yaml:"name" validate:"required,max=50"`
+	Description string   `json:"description" yaml:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+type userFixture struct {
+	Username    string   `json:"username" yaml:"username" validate:"required,min=5,max=30"`
+	Email       string   `json:"email" yaml:"email" validate:"required,email,max=50"`
+	PhoneNumber string   `json:"phone_number" yaml:"phone_number" validate:"max=20"`
+	Password    string   `json:"password" yaml:"password" validate:"required"`
+	Roles       []string `json:"roles" yaml:"roles"`
+	Verified    bool     `json:"verified" yaml:"verified"`
+}
+
+type categoryFixture struct {
+	Name string `json:"name" yaml:"name" validate:"required,max=50"`
+}
+
+type productFixture struct {
+	Name     string  `json:"name" yaml:"name" validate:"required,max=100"`
+	Price    float64 `json:"price" yaml:"price" validate:"gte=0"`
+	Stock    int     `json:"stock" yaml:"stock" validate:"gte=0"`
+	Category string  `json:"category" yaml:"category" validate:"required"`
+}
+
+// seed loads fixtures in one transaction. Rows that already exist, matched by
+// role name, user email, category name and product name within its category,
+// are left alone, so a file can be seeded more than once.
+func seed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: app seed [flags] <file.yaml|file.json>")
+		flags.PrintDefaults()
+	}
+	settings := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+
+	data, err := loadFixtures(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(settings)
+	if err != nil {
+		return err
+	}
+
+	db := config.ConnectDB(cfg)
+	defer config.CloseDB(db)
+
+	hasher := config.NewPasswordHasher(cfg.Auth.Password)
+
+	if err := config.SeedRoles(db); err != nil {
+		return err
+	}
+
+	created := map[string]int{}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, role := range data.Roles {
+			if err := seedRole(tx, role, created); err != nil {
+				return fmt.Errorf("role %s: %w", role.Name, err)
+			}
+		}
+
+		for _, user := range data.Users {
+			if err := seedUser(tx, hasher, user, created); err != nil {
+				return fmt.Errorf("user %s: %w", user.Email, err)
+			}
+		}
+
+		categories := map[string]uint16{}
+
+		for _, category := range data.Categories {
+			id, err := seedCategory(tx, category.Name, created)
+			if err != nil {
+				return fmt.Errorf("category %s: %w", category.Name, err)
+			}
+			categories[category.Name] = id
+		}
+
+		for _, product := range data.Products {
+			if err := seedProduct(tx, product, categories, created); err != nil {
+				return fmt.Errorf("product %s: %w", product.Name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %d roles, %d users, %d categories and %d products\n",
+		created["roles"], created["users"], created["categories"], created["products"])
+
+	return nil
+}
+
+func loadFixtures(path string) (*fixtures, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &fixtures{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(data)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(data)
+	default:
+		return nil, fmt.Errorf("unsupported fixture file %s, use .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if err := validator.New().Struct(data); err != nil {
+		return nil, fmt.Errorf("invalid fixtures in %s: %w", path, err)
+	}
+
+	return data, nil
+}
+
+func seedRole(tx *gorm.DB, fixture roleFixture, created map[string]int) error {
+	role := &model.Role{}
+
+	result := tx.Where(model.Role{Name: fixture.Name}).Attrs(model.Role{Description: fixture.Description}).FirstOrCreate(role)
+	if result.Error != nil {
+		return result.Error
+	}
+	created["roles"] += int(result.RowsAffected)
+
+	if len(fixture.Permissions) == 0 {
+		return nil
+	}
+
+	permissions := make([]model.Permission, 0)
+	if err := tx.Where("name IN ?", fixture.Permissions).Find(&permissions).Error; err != nil {
+		return err
+	}
+
+	if len(permissions) != len(fixture.Permissions) {
+		return errors.New("unknown permission")
+	}
+
+	return tx.Model(role).Association("Permissions").Append(permissions)
+}
+
+func seedUser(tx *gorm.DB, hasher auth.PasswordHasher, fixture userFixture, created map[string]int) error {
+	email := strings.ToLower(strings.TrimSpace(fixture.Email))
+
+	var count int64
+	if err := tx.Model(&model.User{}).Unscoped().Where("email = ?", email).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	roleNames := fixture.Roles
+	if len(roleNames) == 0 {
+		roleNames = []string{"user"}
+	}
+
+	roles := make([]model.Role, 0)
+	if err := tx.Where("name IN ?", roleNames).Find(&roles).Error; err != nil {
+		return err
+	}
+
+	if len(roles) != len(roleNames) {
+		return errors.New("unknown role")
+	}
+
+	hashed, err := hasher.Hash(fixture.Password)
+	if err != nil {
+		return err
+	}
+
+	user := &model.User{
+		Username:    fixture.Username,
+		Email:       email,
+		PhoneNumber: fixture.PhoneNumber,
+		Password:    hashed,
+		Role:        model.RoleUser,
+		Roles:       roles,
+	}
+
+	for _, role := range roles {
+		if role.Name == "admin" {
+			user.Role = model.RoleAdmin
+		}
+	}
+
+	if fixture.Verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	// the roles exist already, only the user and its user_roles rows are new
+	if err := tx.Omit("Roles.*").Create(user).Error; err != nil {
+		return err
+	}
+
+	created["users"]++
+
+	return nil
+}
+
+func seedCategory(tx *gorm.DB, name string, created map[string]int) (uint16, error) {
+	category := &model.Category{}
+
+	result := tx.Where("name = ?", name).Attrs(model.Category{Name: name}).FirstOrCreate(category)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	created["categories"] += int(result.RowsAffected)
+
+	return category.ID, nil
+}
+
+func seedProduct(tx *gorm.DB, fixture productFixture, categories map[string]uint16, created map[string]int) error {
+	categoryID, ok := categories[fixture.Category]
+	if !ok {
+		id, err := seedCategory(tx, fixture.Category, created)
+		if err != nil {
+			return err
+		}
+		categories[fixture.Category] = id
+		categoryID = id
+	}
+
+	product := &model.Product{}
+
+	result := tx.Where("name = ? AND category_id = ?", fixture.Name, categoryID).
+		Attrs(model.Product{
+			Name:       fixture.Name,
+			Price:      fixture.Price,
+			Stock:      fixture.Stock,
+			CategoryID: uint(categoryID),
+		}).
+		FirstOrCreate(product)
+	if result.Error != nil {
+		return result.Error
+	}
+	created["products"] += int(result.RowsAffected)
+
+	return nil
+}
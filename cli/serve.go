@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"flag"
+	"strconv"
+
+	"app/config"
+)
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	settings := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(settings)
+	if err != nil {
+		return err
+	}
+
+	lifecycle := config.NewLifecycle(cfg.Server)
+	app := config.NewAppConfig(cfg, lifecycle)
+
+	return lifecycle.Run(app, ":"+strconv.Itoa(cfg.Server.Port))
+}
@@ -1,6 +1,12 @@
 package config
 
 import (
+	"context"
+	"log"
+
+	"app/audit"
+	"app/auth"
+	"app/lifecycle"
 	"app/middleware"
 	"app/routes"
 	"app/service"
@@ -9,35 +15,80 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"gorm.io/gorm"
 )
 
-func NewAppConfig() *fiber.App {
-	// load dot env
-	LoadEnv()
+func NewAppConfig(config *Config, lifecycle lifecycle.Manager) *fiber.App {
+	db := ConnectDB(config)
+
+	lifecycle.OnShutdown("database", func(ctx context.Context) error {
+		return CloseDB(db)
+	})
+
+	if config.Database.Migrate {
+		if err := MigrateDB(db); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+
+	reads, closeReplicas := ConnectReadDB(config, db)
+
+	lifecycle.OnShutdown("read replicas", func(ctx context.Context) error {
+		return closeReplicas()
+	})
 
-	app := fiber.New()
+	return NewApp(config, db, reads)
+}
+
+// NewApp wires the services and routes on top of an open database. reads is
+// used by the catalog's read-only handlers and may be the same handle as db.
+func NewApp(config *Config, db *gorm.DB, reads *gorm.DB) *fiber.App {
+	app := fiber.New(NewFiberConfig(config.Server))
 
-	app.Use(logger.New())
+	if config.Logging.Requests {
+		app.Use(logger.New())
+	}
 	app.Use(recover.New())
-	app.Use(cors.New())
+	app.Use(cors.New(NewCORSConfig(config.CORS)))
 
 	v1 := app.Group("/api/v1")
 
-	db := ConnectDB()
+	keys := NewKeyManager(config.Auth.JWT)
+	revocations := NewRevocationStore(db, config.Auth.Tokens)
+	sessions := auth.NewSessionManager(db, keys, NewSessionConfig(config.Auth))
+
+	verifier := auth.NewTokenVerifier(keys, NewClaimsConfig(config.Auth.JWT))
+
+	permissions := NewPermissionResolver(db, config.Auth.Tokens)
+	throttle := NewLoginThrottle(db, config.Auth.Login)
+
+	middleware := middleware.NewMiddleware(db, verifier, revocations, permissions, NewTokenExtractors(config.Auth.Tokens))
 
-	middleware := middleware.NewMiddleware(db)
+	recorder := audit.NewRecorder(db)
 
-	categoryService := service.NewCategoryService(db)
-	productService := service.NewProductService(db)
-	userService := service.NewUserService(db)
+	categoryService := service.NewCategoryService(db, reads, recorder)
+	productService := service.NewProductService(db, reads, recorder)
+	userService := service.NewUserService(db, sessions, revocations, keys, throttle, NewPasswordHasher(config.Auth.Password), NewSecretBox(config.Auth.MFA), NewOIDCProviders(config.Auth.OIDC), NewMailer(config.Mail), recorder, NewUserConfig(config.Auth))
+	keyService := service.NewKeyService(keys)
+	adminService := service.NewAdminService(db, sessions, revocations, throttle, recorder)
+	apiKeyService := service.NewApiKeyService(db, recorder)
+	auditService := service.NewAuditService(db)
 
 	categoryRoutes := routes.NewCategoryRoutes(v1, categoryService, middleware)
 	productRoutes := routes.NewProductRoutes(v1, productService, middleware)
 	userRoutes := routes.NewUserRoutes(v1, userService, middleware)
+	adminRoutes := routes.NewAdminRoutes(v1, adminService, middleware)
+	apiKeyRoutes := routes.NewApiKeyRoutes(v1, apiKeyService, middleware)
+	auditRoutes := routes.NewAuditRoutes(v1, auditService, middleware)
+	wellKnownRoutes := routes.NewWellKnownRoutes(app, keyService)
 
 	categoryRoutes.CategoryGroup()
 	productRoutes.ProductGroup()
 	userRoutes.UserGroup()
+	adminRoutes.AdminGroup()
+	apiKeyRoutes.ApiKeyGroup()
+	auditRoutes.AuditGroup()
+	wellKnownRoutes.WellKnownGroup()
 
 	return app
 }
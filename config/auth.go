@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/base64"
+	"log"
+	"strings"
+
+	"app/auth"
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func NewKeyManager(config JWTConfig) auth.KeyManager {
+	keys, err := auth.NewKeyManager(auth.KeyConfig{
+		Algorithm:      config.Algorithm,
+		SecretKey:      []byte(config.SecretKey),
+		PrivateKeyFile: config.PrivateKeyFile,
+		PublicKeysDir:  config.PublicKeysDir,
+	})
+	if err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
+	}
+
+	return keys
+}
+
+func NewSecretBox(config MFAConfig) auth.SecretBox {
+	key, err := base64.StdEncoding.DecodeString(config.EncryptionKey)
+	if err != nil {
+		log.Fatalf("failed to decode the TOTP encryption key: %v", err)
+	}
+
+	secrets, err := auth.NewSecretBox(key)
+	if err != nil {
+		log.Fatalf("failed to load the TOTP encryption key: %v", err)
+	}
+
+	return secrets
+}
+
+func NewSessionConfig(config AuthConfig) auth.SessionConfig {
+	return auth.SessionConfig{
+		AccessTokenTTL:  config.Tokens.AccessTTL,
+		RefreshTokenTTL: config.Tokens.RefreshTTL,
+		Claims:          NewClaimsConfig(config.JWT),
+	}
+}
+
+func NewUserConfig(config AuthConfig) service.UserConfig {
+	return service.UserConfig{
+		PasswordResetURL:                config.Email.PasswordResetURL,
+		PasswordResetTTL:                config.Email.PasswordResetTTL,
+		EmailVerificationURL:            config.Email.VerificationURL,
+		EmailVerificationTTL:            config.Email.VerificationTTL,
+		EmailVerificationResendInterval: config.Email.VerificationResendInterval,
+		RequireEmailVerification:        config.Email.RequireVerification,
+		PasswordPolicy:                  NewPasswordPolicy(config.Password),
+		TOTPIssuer:                      config.MFA.TOTPIssuer,
+		MFAChallengeTTL:                 config.MFA.ChallengeTTL,
+		OIDCStateTTL:                    config.OIDC.StateTTL,
+		Cookies:                         NewCookieConfig(config.Cookies),
+	}
+}
+
+func NewCookieConfig(config CookiesConfig) service.CookieConfig {
+	cookies := service.CookieConfig{
+		Secure: config.Secure,
+		Domain: config.Domain,
+	}
+
+	switch strings.ToLower(config.SameSite) {
+	case "strict":
+		cookies.SameSite = fiber.CookieSameSiteStrictMode
+	case "none":
+		cookies.SameSite = fiber.CookieSameSiteNoneMode
+	default:
+		cookies.SameSite = fiber.CookieSameSiteLaxMode
+	}
+
+	return cookies
+}
+
+func NewPasswordPolicy(config PasswordConfig) auth.PasswordPolicy {
+	maxBytes := 0
+	if config.HashAlgorithm == "bcrypt" {
+		maxBytes = auth.BcryptMaxBytes
+	}
+
+	return auth.PasswordPolicy{
+		MinLength:     config.MinLength,
+		MaxLength:     config.MaxLength,
+		MaxBytes:      maxBytes,
+		RequireUpper:  config.RequireUpper,
+		RequireLower:  config.RequireLower,
+		RequireDigit:  config.RequireDigit,
+		RequireSymbol: config.RequireSymbol,
+		RejectCommon:  config.RejectCommon,
+	}
+}
+
+func NewClaimsConfig(config JWTConfig) auth.ClaimsConfig {
+	return auth.ClaimsConfig{
+		Issuer:   config.Issuer,
+		Audience: config.Audience,
+		Leeway:   config.Leeway,
+	}
+}
+
+func NewRevocationStore(db *gorm.DB, config TokensConfig) auth.RevocationStore {
+	if config.RevocationStore == "memory" {
+		return auth.NewMemoryRevocationStore()
+	}
+	return auth.NewPostgresRevocationStore(db)
+}
+
+func NewLoginThrottle(db *gorm.DB, config LoginConfig) auth.LoginThrottle {
+	var store auth.AttemptStore
+
+	if config.AttemptStore == "memory" {
+		store = auth.NewMemoryAttemptStore()
+	} else {
+		store = auth.NewPostgresAttemptStore(db)
+	}
+
+	return auth.NewLoginThrottle(store, auth.LoginThrottleConfig{
+		FreeAttempts:     config.FreeAttempts,
+		BaseDelay:        config.BackoffBase,
+		MaxDelay:         config.BackoffMax,
+		LockoutThreshold: config.LockoutThreshold,
+		LockoutDuration:  config.LockoutDuration,
+		ResetAfter:       config.AttemptReset,
+	})
+}
+
+func NewPasswordHasher(config PasswordConfig) auth.PasswordHasher {
+	hasher, err := auth.NewPasswordHasher(auth.HasherConfig{
+		Algorithm:  config.HashAlgorithm,
+		BcryptCost: config.BcryptCost,
+		Argon2: auth.Argon2Params{
+			Memory:      config.Argon2Memory,
+			Iterations:  config.Argon2Iterations,
+			Parallelism: config.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	})
+	if err != nil {
+		log.Fatalf("failed to configure password hashing: %v", err)
+	}
+
+	return hasher
+}
+
+func NewOIDCProviders(config OIDCConfig) map[string]auth.OIDCProvider {
+	providers := make(map[string]auth.OIDCProvider)
+
+	for name, provider := range config.Providers {
+		providers[name] = auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}, nil)
+	}
+
+	return providers
+}
+
+func NewPermissionResolver(db *gorm.DB, config TokensConfig) auth.PermissionResolver {
+	return auth.NewPermissionResolver(db, config.PermissionCacheTTL)
+}
+
+func NewTokenExtractors(config TokensConfig) []middleware.TokenExtractor {
+	extractors, err := middleware.ParseTokenLookup(config.Lookup)
+	if err != nil {
+		log.Fatalf("failed to parse auth.tokens.lookup: %v", err)
+	}
+
+	return extractors
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds every setting of the app. The yaml and toml tags name the keys
+// of the config file, env the environment variable overriding it, and the
+// dotted path of yaml names is the command line flag, e.g. -database.host.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	CORS     CORSConfig     `yaml:"cors" toml:"cors"`
+	Logging  LoggingConfig  `yaml:"logging" toml:"logging"`
+	Mail     MailConfig     `yaml:"mail" toml:"mail"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port" toml:"port" env:"PORT" validate:"min=1,max=65535"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" validate:"gt=0"`
+	// ProxyHeader holds the client IP set by a load balancer, e.g.
+	// X-Forwarded-For. It is only read on requests from TrustedProxies, IPs or
+	// CIDR ranges, space separated in TRUSTED_PROXIES.
+	ProxyHeader    string   `yaml:"proxy_header" toml:"proxy_header" env:"PROXY_HEADER"`
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" validate:"dive,ip|cidr"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST" validate:"required"`
+	Port     int    `yaml:"port" toml:"port" env:"DB_PORT" validate:"min=1,max=65535"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER" validate:"required"`
+	Password string `yaml:"password" toml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME" validate:"required"`
+	SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode" env:"DB_SSL_MODE" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	TimeZone string `yaml:"time_zone" toml:"time_zone" env:"DB_TIMEZONE" validate:"required"`
+	// Migrate applies pending migrations on boot
+	Migrate bool `yaml:"migrate" toml:"migrate" env:"MIGRATE"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" validate:"min=0"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" validate:"min=0"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" validate:"min=0"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" validate:"min=0"`
+
+	// ConnectRetries is how often connecting is retried on startup, waiting
+	// ConnectBackoff, doubled after every attempt up to ConnectBackoffMax
+	ConnectRetries    int           `yaml:"connect_retries" toml:"connect_retries" env:"DB_CONNECT_RETRIES" validate:"min=0"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff" toml:"connect_backoff" env:"DB_CONNECT_BACKOFF" validate:"gt=0"`
+	ConnectBackoffMax time.Duration `yaml:"connect_backoff_max" toml:"connect_backoff_max" env:"DB_CONNECT_BACKOFF_MAX" validate:"gtefield=ConnectBackoff"`
+
+	// Replicas are connection strings of read replicas, space separated in
+	// DB_REPLICAS. Handlers that only read are routed to them.
+	Replicas []string `yaml:"replicas" toml:"replicas" env:"DB_REPLICAS"`
+}
+
+type AuthConfig struct {
+	JWT      JWTConfig      `yaml:"jwt" toml:"jwt"`
+	Tokens   TokensConfig   `yaml:"tokens" toml:"tokens"`
+	Cookies  CookiesConfig  `yaml:"cookies" toml:"cookies"`
+	Password PasswordConfig `yaml:"password" toml:"password"`
+	Login    LoginConfig    `yaml:"login" toml:"login"`
+	Email    EmailConfig    `yaml:"email" toml:"email"`
+	MFA      MFAConfig      `yaml:"mfa" toml:"mfa"`
+	OIDC     OIDCConfig     `yaml:"oidc" toml:"oidc"`
+}
+
+type JWTConfig struct {
+	Algorithm      string        `yaml:"algorithm" toml:"algorithm" env:"JWT_ALGORITHM" validate:"oneof=HS256 RS256 EdDSA"`
+	SecretKey      string        `yaml:"secret_key" toml:"secret_key" env:"SECRET_KEY"`
+	PrivateKeyFile string        `yaml:"private_key_file" toml:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	PublicKeysDir  string        `yaml:"public_keys_dir" toml:"public_keys_dir" env:"JWT_PUBLIC_KEYS_DIR"`
+	Issuer         string        `yaml:"issuer" toml:"issuer" env:"JWT_ISSUER"`
+	Audience       string        `yaml:"audience" toml:"audience" env:"JWT_AUDIENCE"`
+	Leeway         time.Duration `yaml:"leeway" toml:"leeway" env:"JWT_LEEWAY" validate:"min=0"`
+}
+
+type TokensConfig struct {
+	AccessTTL          time.Duration `yaml:"access_ttl" toml:"access_ttl" env:"ACCESS_TOKEN_TTL" validate:"gt=0"`
+	RefreshTTL         time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"REFRESH_TOKEN_TTL" validate:"gt=0"`
+	Lookup             string        `yaml:"lookup" toml:"lookup" env:"TOKEN_LOOKUP" validate:"required"`
+	RevocationStore    string        `yaml:"revocation_store" toml:"revocation_store" env:"REVOCATION_STORE" validate:"oneof=memory postgres"`
+	PermissionCacheTTL time.Duration `yaml:"permission_cache_ttl" toml:"permission_cache_ttl" env:"PERMISSION_CACHE_TTL" validate:"min=0"`
+}
+
+type CookiesConfig struct {
+	SameSite string `yaml:"same_site" toml:"same_site" env:"COOKIE_SAME_SITE" validate:"oneof=Lax Strict None lax strict none"`
+	Secure   bool   `yaml:"secure" toml:"secure" env:"COOKIE_SECURE"`
+	Domain   string `yaml:"domain" toml:"domain" env:"COOKIE_DOMAIN"`
+}
+
+type PasswordConfig struct {
+	MinLength         int    `yaml:"min_length" toml:"min_length" env:"PASSWORD_MIN_LENGTH" validate:"min=1"`
+	MaxLength         int    `yaml:"max_length" toml:"max_length" env:"PASSWORD_MAX_LENGTH" validate:"gtefield=MinLength"`
+	RequireUpper      bool   `yaml:"require_upper" toml:"require_upper" env:"PASSWORD_REQUIRE_UPPER"`
+	RequireLower      bool   `yaml:"require_lower" toml:"require_lower" env:"PASSWORD_REQUIRE_LOWER"`
+	RequireDigit      bool   `yaml:"require_digit" toml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol     bool   `yaml:"require_symbol" toml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	RejectCommon      bool   `yaml:"reject_common" toml:"reject_common" env:"PASSWORD_REJECT_COMMON"`
+	HashAlgorithm     string `yaml:"hash_algorithm" toml:"hash_algorithm" env:"PASSWORD_HASH_ALGORITHM" validate:"oneof=argon2id bcrypt"`
+	BcryptCost        int    `yaml:"bcrypt_cost" toml:"bcrypt_cost" env:"BCRYPT_COST" validate:"min=4,max=31"`
+	Argon2Memory      uint32 `yaml:"argon2_memory" toml:"argon2_memory" env:"ARGON2_MEMORY" validate:"gt=0"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" toml:"argon2_iterations" env:"ARGON2_ITERATIONS" validate:"gt=0"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" toml:"argon2_parallelism" env:"ARGON2_PARALLELISM" validate:"gt=0"`
+}
+
+type LoginConfig struct {
+	AttemptStore     string        `yaml:"attempt_store" toml:"attempt_store" env:"LOGIN_ATTEMPT_STORE" validate:"oneof=memory postgres"`
+	FreeAttempts     int           `yaml:"free_attempts" toml:"free_attempts" env:"LOGIN_FREE_ATTEMPTS" validate:"min=0"`
+	BackoffBase      time.Duration `yaml:"backoff_base" toml:"backoff_base" env:"LOGIN_BACKOFF_BASE" validate:"min=0"`
+	BackoffMax       time.Duration `yaml:"backoff_max" toml:"backoff_max" env:"LOGIN_BACKOFF_MAX" validate:"gtefield=BackoffBase"`
+	LockoutThreshold int           `yaml:"lockout_threshold" toml:"lockout_threshold" env:"LOGIN_LOCKOUT_THRESHOLD" validate:"min=0"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration" toml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION" validate:"min=0"`
+	AttemptReset     time.Duration `yaml:"attempt_reset" toml:"attempt_reset" env:"LOGIN_ATTEMPT_RESET" validate:"gt=0"`
+}
+
+type EmailConfig struct {
+	PasswordResetURL           string        `yaml:"password_reset_url" toml:"password_reset_url" env:"PASSWORD_RESET_URL" validate:"omitempty,url"`
+	PasswordResetTTL           time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl" env:"PASSWORD_RESET_TTL" validate:"gt=0"`
+	VerificationURL            string        `yaml:"verification_url" toml:"verification_url" env:"EMAIL_VERIFICATION_URL" validate:"omitempty,url"`
+	VerificationTTL            time.Duration `yaml:"verification_ttl" toml:"verification_ttl" env:"EMAIL_VERIFICATION_TTL" validate:"gt=0"`
+	VerificationResendInterval time.Duration `yaml:"verification_resend_interval" toml:"verification_resend_interval" env:"EMAIL_VERIFICATION_RESEND_INTERVAL" validate:"min=0"`
+	RequireVerification        bool          `yaml:"require_verification" toml:"require_verification" env:"REQUIRE_EMAIL_VERIFICATION"`
+}
+
+type MFAConfig struct {
+	TOTPIssuer   string        `yaml:"totp_issuer" toml:"totp_issuer" env:"TOTP_ISSUER" validate:"required"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" toml:"challenge_ttl" env:"MFA_CHALLENGE_TTL" validate:"gt=0"`
+	// EncryptionKey encrypts TOTP secrets in the database: 32 random bytes,
+	// base64 encoded, e.g. from openssl rand -base64 32. Changing it locks out
+	// every user enrolled under the old key. There is no default, not even in
+	// .env, so every deployment has to pick its own.
+	EncryptionKey string `yaml:"encryption_key" toml:"encryption_key" env:"TOTP_ENCRYPTION_KEY"`
+}
+
+type OIDCConfig struct {
+	StateTTL time.Duration `yaml:"state_ttl" toml:"state_ttl" env:"OIDC_STATE_TTL" validate:"gt=0"`
+	// Providers are keyed by the name used in the login URL. From the
+	// environment they are listed in OIDC_PROVIDERS and configured with
+	// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES.
+	Providers map[string]OIDCProviderConfig `yaml:"providers" toml:"providers" validate:"dive"`
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string   `yaml:"issuer" toml:"issuer" validate:"required,url"`
+	ClientID     string   `yaml:"client_id" toml:"client_id" validate:"required"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url" validate:"required,url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+type CORSConfig struct {
+	AllowOrigins string `yaml:"allow_origins" toml:"allow_origins" env:"CORS_ALLOW_ORIGINS" validate:"required"`
+	MaxAge       int    `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE" validate:"min=0"`
+}
+
+type LoggingConfig struct {
+	// Level is the level of the SQL logger
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" validate:"oneof=silent error warn info"`
+	// Requests logs every HTTP request
+	Requests bool `yaml:"requests" toml:"requests" env:"LOG_REQUESTS"`
+}
+
+type MailConfig struct {
+	// Driver defaults to smtp, so a deployment without mail settings fails at
+	// startup. The log driver only logs recipients and subjects.
+	Driver string     `yaml:"driver" toml:"driver" env:"MAIL_DRIVER" validate:"oneof=log file smtp"`
+	From   string     `yaml:"from" toml:"from" env:"MAIL_FROM" validate:"required"`
+	Dir    string     `yaml:"dir" toml:"dir" env:"MAIL_DIR"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" toml:"port" env:"SMTP_PORT" validate:"min=1,max=65535"`
+	Username string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASSWORD"`
+}
+
+// Defaults returns the settings used for everything the file, the environment
+// and the flags leave out.
+func Defaults() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			SSLMode:  "disable",
+			TimeZone: "UTC",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ConnectRetries:    5,
+			ConnectBackoff:    time.Second,
+			ConnectBackoffMax: 30 * time.Second,
+		},
+		Auth: AuthConfig{
+			JWT: JWTConfig{
+				Algorithm: "HS256",
+				Leeway:    30 * time.Second,
+			},
+			Tokens: TokensConfig{
+				AccessTTL:          15 * time.Minute,
+				RefreshTTL:         7 * 24 * time.Hour,
+				Lookup:             "header:Authorization,cookie:token",
+				RevocationStore:    "postgres",
+				PermissionCacheTTL: time.Minute,
+			},
+			Cookies: CookiesConfig{
+				SameSite: "Lax",
+			},
+			Password: PasswordConfig{
+				MinLength:         8,
+				MaxLength:         64,
+				RejectCommon:      true,
+				HashAlgorithm:     "argon2id",
+				BcryptCost:        bcrypt.DefaultCost,
+				Argon2Memory:      19456,
+				Argon2Iterations:  2,
+				Argon2Parallelism: 1,
+			},
+			Login: LoginConfig{
+				AttemptStore:     "postgres",
+				FreeAttempts:     3,
+				BackoffBase:      time.Second,
+				BackoffMax:       15 * time.Minute,
+				LockoutThreshold: 10,
+				LockoutDuration:  30 * time.Minute,
+				AttemptReset:     24 * time.Hour,
+			},
+			Email: EmailConfig{
+				PasswordResetTTL:           30 * time.Minute,
+				VerificationTTL:            24 * time.Hour,
+				VerificationResendInterval: time.Minute,
+			},
+			MFA: MFAConfig{
+				TOTPIssuer:   "Go Fiber BoilerPlate",
+				ChallengeTTL: 5 * time.Minute,
+			},
+			OIDC: OIDCConfig{
+				StateTTL: 10 * time.Minute,
+			},
+		},
+		CORS: CORSConfig{
+			AllowOrigins: "http://localhost:3000",
+			MaxAge:       600,
+		},
+		Logging: LoggingConfig{
+			Level:    "info",
+			Requests: true,
+		},
+		Mail: MailConfig{
+			Driver: "smtp",
+			From:   "no-reply@localhost",
+			Dir:    "tmp/mail",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+	}
+}
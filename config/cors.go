@@ -0,0 +1,21 @@
+package config
+
+import (
+	"strings"
+
+	"app/auth"
+
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// NewCORSConfig only allows the configured origins. Credentials are allowed
+// for an explicit list of origins, never for "*".
+func NewCORSConfig(config CORSConfig) cors.Config {
+	return cors.Config{
+		AllowOrigins:     config.AllowOrigins,
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     strings.Join([]string{"Origin", "Content-Type", "Accept", "Authorization", auth.CSRFHeaderName, "X-API-Key"}, ","),
+		AllowCredentials: config.AllowOrigins != "*",
+		MaxAge:           config.MaxAge,
+	}
+}
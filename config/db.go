@@ -1,55 +1,199 @@
 package config
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-
-	"app/model"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
-func ConnectDB() *gorm.DB {
+// ConnectDB connects to the primary database. While Postgres is not reachable
+// yet it retries with exponential backoff, so the app does not crash loop when
+// it starts first.
+func ConnectDB(config *Config) *gorm.DB {
 	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Jakarta",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+		dsnValue(config.Database.Host),
+		dsnValue(config.Database.User),
+		dsnValue(config.Database.Password),
+		dsnValue(config.Database.Name),
+		config.Database.Port,
+		dsnValue(config.Database.SSLMode),
+		dsnValue(config.Database.TimeZone),
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	backoff := config.Database.ConnectBackoff
 
-	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+	for attempt := 0; ; attempt++ {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+			Logger: NewDBLogger(config.Logging),
+		})
+
+		if err == nil {
+			if err := configurePool(db, config.Database); err != nil {
+				log.Fatalf("failed to configure connection pool: %v", err)
+			}
+
+			log.Println("connected to database")
+
+			return db
+		}
+
+		// a failed ping still leaves a pool behind
+		if db != nil {
+			CloseDB(db)
+		}
+
+		if attempt >= config.Database.ConnectRetries {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+
+		log.Printf("database is not reachable, retrying in %s (%d/%d): %v", backoff, attempt+1, config.Database.ConnectRetries, err)
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > config.Database.ConnectBackoffMax {
+			backoff = config.Database.ConnectBackoffMax
+		}
 	}
+}
 
-	log.Println("connected to database")
-	db.Logger = logger.Default.LogMode(logger.Info)
+// ConnectReadDB returns the handle for read-only handlers. Its queries go to a
+// random replica and anything else to the primary, whose pool it shares.
+// Replicas that do not answer a ping on startup are left out; without any it
+// is the primary itself. close releases the replica connections only.
+func ConnectReadDB(config *Config, db *gorm.DB) (reads *gorm.DB, close func() error) {
+	noReplicas := func() error { return nil }
 
-	if migrate := os.Getenv("MIGRATE"); migrate == "TRUE" {
-		log.Println("running migrations")
+	replicas := make([]gorm.Dialector, 0, len(config.Database.Replicas))
+	pools := make([]*sql.DB, 0, len(config.Database.Replicas))
 
-		err = db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"").Error
+	for i, dsn := range config.Database.Replicas {
+		replica, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
 		if err != nil {
-			log.Fatalf("failed to create extension: %v", err)
+			if replica != nil {
+				CloseDB(replica)
+			}
+			log.Printf("read replica %d is not reachable, leaving it out: %v", i+1, err)
+			continue
+		}
+
+		pool, err := replica.DB()
+		if err != nil {
+			log.Fatalf("failed to get the replica connection pool: %v", err)
+		}
+
+		replicas = append(replicas, postgres.New(postgres.Config{Conn: pool}))
+		pools = append(pools, pool)
+	}
+
+	closeReplicas := func() error {
+		var errs []error
+		for _, pool := range pools {
+			errs = append(errs, pool.Close())
 		}
+		return errors.Join(errs...)
+	}
 
-		if err := db.AutoMigrate(&model.User{}); err != nil {
-			log.Fatalf("failed to perform auto migration: %v", err)
+	if len(replicas) == 0 {
+		if len(config.Database.Replicas) > 0 {
+			log.Println("no read replica is reachable, reading from the primary")
 		}
+		return db, noReplicas
+	}
+
+	primary, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get the primary connection pool: %v", err)
+	}
+
+	reads, err = gorm.Open(postgres.New(postgres.Config{Conn: primary}), &gorm.Config{
+		Logger: NewDBLogger(config.Logging),
+	})
+	if err != nil {
+		log.Fatalf("failed to create the read handle: %v", err)
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxOpenConns(config.Database.MaxOpenConns).
+		SetMaxIdleConns(config.Database.MaxIdleConns).
+		SetConnMaxLifetime(config.Database.ConnMaxLifetime).
+		SetConnMaxIdleTime(config.Database.ConnMaxIdleTime)
+
+	if err := reads.Use(resolver); err != nil {
+		log.Printf("failed to use the read replicas, reading from the primary: %v", err)
+		closeReplicas()
+		return db, noReplicas
+	}
+
+	log.Printf("connected to %d of %d read replicas", len(replicas), len(config.Database.Replicas))
+
+	return reads, closeReplicas
+}
+
+func configurePool(db *gorm.DB, config DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	return nil
+}
+
+func NewDBLogger(config LoggingConfig) logger.Interface {
+	levels := map[string]logger.LogLevel{
+		"silent": logger.Silent,
+		"error":  logger.Error,
+		"warn":   logger.Warn,
+		"info":   logger.Info,
+	}
+
+	return logger.Default.LogMode(levels[config.Level])
+}
+
+// dsnValue quotes a value for a key=value connection string, so passwords
+// with spaces or quotes work.
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// NewOfflineDB returns a handle that never connects, for building the app
+// without a database, e.g. to list its routes.
+func NewOfflineDB() *gorm.DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		log.Fatalf("failed to create database handle: %v", err)
 	}
 
 	return db
 }
 
-func CloseDB(db *gorm.DB) {
-	sqlDB, _ := db.DB()
-	sqlDB.Close()
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
 }
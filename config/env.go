@@ -1,11 +1,19 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// LoadEnv reads .env outside production. A missing file is not an error.
 func LoadEnv() error {
 	prod := os.Getenv("PROD")
 
@@ -14,9 +22,140 @@ func LoadEnv() error {
 	}
 
 	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// applyEnv overrides every field with an env tag whose variable is set to a
+// non-empty value.
+func applyEnv(config *Config) error {
+	err := walkFields(reflect.ValueOf(config).Elem(), "", func(field reflect.Value, tag reflect.StructTag, path string) error {
+		name := tag.Get("env")
+		if name == "" {
+			return nil
+		}
+
+		value := os.Getenv(name)
+		if value == "" {
+			return nil
+		}
+
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
+	return applyOIDCEnv(&config.Auth.OIDC)
+}
+
+func applyOIDCEnv(config *OIDCConfig) error {
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if config.Providers == nil {
+			config.Providers = make(map[string]OIDCProviderConfig)
+		}
+
+		provider := config.Providers[name]
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		for suffix, field := range map[string]*string{
+			"ISSUER":        &provider.Issuer,
+			"CLIENT_ID":     &provider.ClientID,
+			"CLIENT_SECRET": &provider.ClientSecret,
+			"REDIRECT_URL":  &provider.RedirectURL,
+		} {
+			if value := os.Getenv(prefix + suffix); value != "" {
+				*field = value
+			}
+		}
+
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			provider.Scopes = strings.Fields(scopes)
+		}
+
+		config.Providers[name] = provider
+	}
+
+	return nil
+}
+
+// walkFields calls fn for every settable leaf of the struct, with the dotted
+// path of yaml names leading to it. Maps are skipped, they are read by hand.
+func walkFields(value reflect.Value, prefix string, fn func(field reflect.Value, tag reflect.StructTag, path string) error) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		info := value.Type().Field(i)
+
+		path := strings.Split(info.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := walkFields(field, path, fn); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Map:
+			continue
+		default:
+			if err := fn(field, info.Tag, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, use TRUE or FALSE", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint8, reflect.Uint32:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(strings.Fields(value)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyOIDCEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		file map[string]OIDCProviderConfig
+		env  map[string]string
+		want map[string]OIDCProviderConfig
+	}{
+		{
+			name: "none listed",
+			env:  map[string]string{"OIDC_GOOGLE_CLIENT_ID": "unused"},
+		},
+		{
+			name: "listed providers",
+			env: map[string]string{
+				"OIDC_PROVIDERS":            "google, okta",
+				"OIDC_GOOGLE_ISSUER":        "https://accounts.google.com",
+				"OIDC_GOOGLE_CLIENT_ID":     "google-client",
+				"OIDC_GOOGLE_CLIENT_SECRET": "google-secret",
+				"OIDC_GOOGLE_REDIRECT_URL":  "https://app.example.com/callback",
+				"OIDC_GOOGLE_SCOPES":        "openid email",
+				"OIDC_OKTA_ISSUER":          "https://example.okta.com",
+			},
+			want: map[string]OIDCProviderConfig{
+				"google": {
+					Issuer:       "https://accounts.google.com",
+					ClientID:     "google-client",
+					ClientSecret: "google-secret",
+					RedirectURL:  "https://app.example.com/callback",
+					Scopes:       []string{"openid", "email"},
+				},
+				"okta": {Issuer: "https://example.okta.com"},
+			},
+		},
+		{
+			name: "env completes the file",
+			file: map[string]OIDCProviderConfig{
+				"google": {Issuer: "https://accounts.google.com", ClientID: "from-file", Scopes: []string{"openid"}},
+			},
+			env: map[string]string{
+				"OIDC_PROVIDERS":            "google",
+				"OIDC_GOOGLE_CLIENT_ID":     "from-env",
+				"OIDC_GOOGLE_CLIENT_SECRET": "secret",
+			},
+			want: map[string]OIDCProviderConfig{
+				"google": {Issuer: "https://accounts.google.com", ClientID: "from-env", ClientSecret: "secret", Scopes: []string{"openid"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("OIDC_PROVIDERS", "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			config := OIDCConfig{Providers: test.file}
+
+			if err := applyOIDCEnv(&config); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(config.Providers, test.want) {
+				t.Errorf("providers = %+v, want %+v", config.Providers, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+import (
+	"app/lifecycle"
+)
+
+func NewLifecycle(config ServerConfig) lifecycle.Manager {
+	return lifecycle.NewManager(lifecycle.Config{
+		ShutdownTimeout: config.ShutdownTimeout,
+	})
+}
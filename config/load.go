@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Flags holds the command line overrides registered by RegisterFlags.
+type Flags struct {
+	file   string
+	values map[string]string
+}
+
+type flagValue struct {
+	path   string
+	values map[string]string
+}
+
+func (v *flagValue) String() string {
+	if v.values == nil {
+		return ""
+	}
+	return v.values[v.path]
+}
+
+func (v *flagValue) Set(value string) error {
+	v.values[v.path] = value
+	return nil
+}
+
+// RegisterFlags adds -config and a flag per setting, named after its path in
+// the config file, e.g. -database.host or -auth.tokens.access_ttl.
+func RegisterFlags(flags *flag.FlagSet) *Flags {
+	registered := &Flags{values: map[string]string{}}
+
+	flags.StringVar(&registered.file, "config", "", "YAML or TOML config file, defaults to CONFIG_FILE")
+
+	defaults := Defaults()
+
+	walkFields(reflect.ValueOf(&defaults).Elem(), "", func(field reflect.Value, tag reflect.StructTag, path string) error {
+		usage := "overrides " + tag.Get("env")
+		flags.Var(&flagValue{path: path, values: registered.values}, path, usage)
+		return nil
+	})
+
+	return registered
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and the flags, each overriding the ones before, and validates
+// the result. flags may be nil.
+func Load(flags *Flags) (*Config, error) {
+	config, err := load(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadOffline is Load for commands that build the app without serving it or
+// touching the database, such as listing the routes. Settings only needed to
+// connect, sign tokens or send mail may be missing; they get placeholders.
+func LoadOffline(flags *Flags) (*Config, error) {
+	config, err := load(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	config.offline()
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) offline() {
+	if c.Database.User == "" {
+		c.Database.User = "offline"
+	}
+	if c.Database.Name == "" {
+		c.Database.Name = "offline"
+	}
+
+	// no token is ever signed, so key files are not read either
+	c.Auth.JWT.Algorithm = "HS256"
+	c.Auth.JWT.SecretKey = "offline"
+	c.Auth.JWT.PrivateKeyFile = ""
+	c.Auth.JWT.PublicKeysDir = ""
+
+	c.Auth.MFA.EncryptionKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	c.Mail.Driver = "log"
+}
+
+func load(flags *Flags) (*Config, error) {
+	if err := LoadEnv(); err != nil {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	config := Defaults()
+
+	file := os.Getenv("CONFIG_FILE")
+	if flags != nil && flags.file != "" {
+		file = flags.file
+	}
+
+	if file != "" {
+		if err := loadFile(&config, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&config); err != nil {
+		return nil, fmt.Errorf("invalid environment variable %w", err)
+	}
+
+	if flags != nil {
+		if err := applyFlags(&config, flags.values); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+func loadFile(config *Config, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(content), config)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown setting %s", meta.Undecoded()[0])
+		}
+	default:
+		return fmt.Errorf("unsupported config file %s, use .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyFlags(config *Config, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	errs := make([]string, 0)
+
+	walkFields(reflect.ValueOf(config).Elem(), "", func(field reflect.Value, tag reflect.StructTag, path string) error {
+		value, ok := values[path]
+		if !ok {
+			return nil
+		}
+
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Sprintf("-%s: %v", path, err))
+		}
+		return nil
+	})
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New("invalid flag " + strings.Join(errs, ", "))
+	}
+
+	return nil
+}
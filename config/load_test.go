@@ -0,0 +1,279 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadOffline(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantLoad    string
+		wantOffline string
+	}{
+		{
+			name:     "nothing configured",
+			wantLoad: "database.name is required",
+		},
+		{
+			name:     "asymmetric keys without a key file",
+			env:      map[string]string{"JWT_ALGORITHM": "RS256", "DB_USER": "app", "DB_NAME": "app"},
+			wantLoad: "auth.jwt.private_key_file is required for RS256",
+		},
+		{
+			name:        "invalid setting",
+			env:         map[string]string{"LOG_LEVEL": "loud"},
+			wantLoad:    "logging.level",
+			wantOffline: "logging.level",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), test.wantLoad) {
+				t.Errorf("Load error = %v, want one about %q", err, test.wantLoad)
+			}
+
+			config, err := LoadOffline(nil)
+
+			if test.wantOffline != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantOffline) {
+					t.Errorf("LoadOffline error = %v, want one about %q", err, test.wantOffline)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoadOffline: %v", err)
+			}
+
+			if config.Mail.Driver != "log" || config.Auth.JWT.Algorithm != "HS256" {
+				t.Errorf("offline config mails with %s and signs with %s, want log and HS256", config.Mail.Driver, config.Auth.JWT.Algorithm)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := "server:\n  port: 9000\n  shutdown_timeout: 20s\ndatabase:\n  host: file-host\n"
+	tomlFile := "[server]\nport = 9000\nshutdown_timeout = \"20s\"\n\n[database]\nhost = \"file-host\"\n"
+
+	tests := []struct {
+		name         string
+		file         string
+		content      string
+		env          map[string]string
+		args         []string
+		wantPort     int
+		wantHost     string
+		wantShutdown time.Duration
+	}{
+		{
+			name:         "defaults",
+			wantPort:     8080,
+			wantHost:     "localhost",
+			wantShutdown: 10 * time.Second,
+		},
+		{
+			name:         "yaml file over defaults",
+			file:         "config.yaml",
+			content:      yamlFile,
+			wantPort:     9000,
+			wantHost:     "file-host",
+			wantShutdown: 20 * time.Second,
+		},
+		{
+			name:         "toml file over defaults",
+			file:         "config.toml",
+			content:      tomlFile,
+			wantPort:     9000,
+			wantHost:     "file-host",
+			wantShutdown: 20 * time.Second,
+		},
+		{
+			name:         "env over file",
+			file:         "config.yml",
+			content:      yamlFile,
+			env:          map[string]string{"PORT": "9100", "SHUTDOWN_TIMEOUT": "30s"},
+			wantPort:     9100,
+			wantHost:     "file-host",
+			wantShutdown: 30 * time.Second,
+		},
+		{
+			name:         "flags over env",
+			file:         "config.yaml",
+			content:      yamlFile,
+			env:          map[string]string{"PORT": "9100", "DB_HOST": "env-host"},
+			args:         []string{"-server.port=9200"},
+			wantPort:     9200,
+			wantHost:     "env-host",
+			wantShutdown: 20 * time.Second,
+		},
+		{
+			name:         "empty env is ignored",
+			file:         "config.yaml",
+			content:      yamlFile,
+			env:          map[string]string{"PORT": ""},
+			wantPort:     9000,
+			wantHost:     "file-host",
+			wantShutdown: 20 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for _, name := range []string{"PORT", "SHUTDOWN_TIMEOUT", "DB_HOST"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeFile(t, test.file, test.content)}, args...)
+			}
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			registered := RegisterFlags(flags)
+			if err := flags.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := load(registered)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.Server.Port != test.wantPort || config.Database.Host != test.wantHost || config.Server.ShutdownTimeout != test.wantShutdown {
+				t.Errorf("port, host, shutdown = %d, %s, %s, want %d, %s, %s",
+					config.Server.Port, config.Database.Host, config.Server.ShutdownTimeout,
+					test.wantPort, test.wantHost, test.wantShutdown)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", "database:\n  name: from-file\n"))
+
+	config, err := load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Database.Name != "from-file" {
+		t.Errorf("database.name = %q, want the one from CONFIG_FILE", config.Database.Name)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown yaml setting",
+			file:    "config.yaml",
+			content: "server:\n  prot: 9000\n",
+			wantErr: "field prot not found",
+		},
+		{
+			name:    "unknown toml setting",
+			file:    "config.toml",
+			content: "[server]\nprot = 9000\n",
+			wantErr: "unknown setting server.prot",
+		},
+		{
+			name:    "malformed yaml",
+			file:    "config.yaml",
+			content: "server: [",
+			wantErr: "failed to parse",
+		},
+		{
+			name:    "unsupported file",
+			file:    "config.json",
+			content: "{}",
+			wantErr: "unsupported config file",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"-config", "does-not-exist.yaml"},
+			wantErr: "failed to read config file",
+		},
+		{
+			name:    "invalid number",
+			env:     map[string]string{"PORT": "eighty"},
+			wantErr: `invalid environment variable PORT: invalid number "eighty"`,
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{"ACCESS_TOKEN_TTL": "15"},
+			wantErr: `invalid environment variable ACCESS_TOKEN_TTL: invalid duration "15"`,
+		},
+		{
+			name:    "invalid boolean",
+			env:     map[string]string{"COOKIE_SECURE": "yes"},
+			wantErr: `invalid environment variable COOKIE_SECURE: invalid boolean "yes"`,
+		},
+		{
+			name:    "number out of range",
+			env:     map[string]string{"ARGON2_PARALLELISM": "256"},
+			wantErr: `invalid environment variable ARGON2_PARALLELISM: invalid number "256"`,
+		},
+		{
+			name:    "invalid flags are all reported",
+			args:    []string{"-server.port=x", "-auth.tokens.access_ttl=y"},
+			wantErr: `invalid flag -auth.tokens.access_ttl: invalid duration "y", -server.port: invalid number "x"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeFile(t, test.file, test.content)}, args...)
+			}
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			registered := RegisterFlags(flags)
+			if err := flags.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := load(registered); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want one about %q", err, test.wantErr)
+			}
+		})
+	}
+}
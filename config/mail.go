@@ -0,0 +1,25 @@
+package config
+
+import (
+	"log"
+	"strconv"
+
+	"app/mailer"
+)
+
+func NewMailer(config MailConfig) mailer.Mailer {
+	switch config.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.SMTP.Host,
+			Port:     strconv.Itoa(config.SMTP.Port),
+			Username: config.SMTP.Username,
+			Password: config.SMTP.Password,
+			From:     config.From,
+		})
+	case "file":
+		return mailer.NewFileMailer(config.Dir, config.From)
+	default:
+		return mailer.NewLogMailer(log.Default())
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"log"
+
+	"app/migrations"
+
+	"gorm.io/gorm"
+)
+
+func NewMigrator(db *gorm.DB) migrations.Migrator {
+	embedded, err := migrations.Embedded()
+	if err != nil {
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+
+	return migrations.NewMigrator(db, embedded)
+}
+
+// MigrateDB applies every pending migration and seeds the built-in roles. It
+// runs on boot when database.migrate is set.
+func MigrateDB(db *gorm.DB) error {
+	if _, err := NewMigrator(db).Up(0); err != nil {
+		return err
+	}
+
+	return SeedRoles(db)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"app/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var defaultPermissions = []model.Permission{
+	{Name: "product:create", Description: "Create products"},
+	{Name: "product:update", Description: "Update products"},
+	{Name: "product:delete", Description: "Delete products"},
+	{Name: "product:update:own", Description: "Update own products"},
+	{Name: "product:delete:own", Description: "Delete own products"},
+	{Name: "category:create", Description: "Create categories"},
+	{Name: "category:update", Description: "Update categories"},
+	{Name: "category:delete", Description: "Delete categories"},
+	{Name: "category:update:own", Description: "Update own categories"},
+	{Name: "category:delete:own", Description: "Delete own categories"},
+	{Name: "role:manage", Description: "Assign and remove user roles"},
+	{Name: "user:manage", Description: "Manage user accounts"},
+	{Name: "audit:read", Description: "Read and export the audit log"},
+}
+
+var defaultRoles = map[string][]string{
+	"admin": {
+		"product:create", "product:update", "product:delete",
+		"category:create", "category:update", "category:delete",
+		"role:manage", "user:manage", "audit:read",
+	},
+	// the :own permissions are what API keys get scoped to for the owner's
+	// resources, tokens reach those without them
+	"user": {
+		"product:create", "product:update:own", "product:delete:own",
+		"category:create", "category:update:own", "category:delete:own",
+	},
+}
+
+// SeedRoles creates the built-in roles and permissions and gives every user
+// without a named role the one matching their legacy numeric role.
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		permissions := append([]model.Permission(nil), defaultPermissions...)
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&permissions).Error; err != nil {
+			return err
+		}
+
+		for name, permissionNames := range defaultRoles {
+			role := &model.Role{}
+			if err := tx.Where(model.Role{Name: name}).FirstOrCreate(role).Error; err != nil {
+				return err
+			}
+
+			permissions := make([]model.Permission, 0)
+			if err := tx.Where("name IN ?", permissionNames).Find(&permissions).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+
+		return tx.Exec(`
+			INSERT INTO user_roles (user_id, role_id)
+			SELECT users.id, roles.id FROM users
+			JOIN roles ON roles.name = CASE WHEN users.role = 1 THEN 'admin' ELSE 'user' END
+			WHERE NOT EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id)
+		`).Error
+	})
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// NewFiberConfig makes c.IP() report the client behind a trusted load
+// balancer instead of the balancer itself, so per-IP limits such as the login
+// throttle see individual clients.
+func NewFiberConfig(config ServerConfig) fiber.Config {
+	if config.ProxyHeader == "" {
+		return fiber.Config{}
+	}
+
+	return fiber.Config{
+		ProxyHeader:             config.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          config.TrustedProxies,
+		EnableIPValidation:      true,
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"app/middleware"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validate reports every invalid setting at once, by its path in the config
+// file.
+func (c *Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("yaml"), ",")[0]
+	})
+
+	problems := make([]string, 0)
+
+	var fieldErrors validator.ValidationErrors
+	if err := validate.Struct(c); errors.As(err, &fieldErrors) {
+		for _, fieldError := range fieldErrors {
+			problems = append(problems, describeFieldError(fieldError))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if c.Server.ProxyHeader != "" && len(c.Server.TrustedProxies) == 0 {
+		// otherwise every client could pick the IP the login throttle counts
+		problems = append(problems, "server.trusted_proxies is required with server.proxy_header")
+	}
+
+	if strings.EqualFold(c.Auth.Cookies.SameSite, "none") && !c.Auth.Cookies.Secure {
+		// browsers drop SameSite=None cookies that are not Secure
+		problems = append(problems, "auth.cookies.same_site None requires auth.cookies.secure")
+	}
+
+	switch c.Auth.JWT.Algorithm {
+	case "HS256":
+		if c.Auth.JWT.SecretKey == "" {
+			problems = append(problems, "auth.jwt.secret_key is required for HS256")
+		}
+	default:
+		if c.Auth.JWT.PrivateKeyFile == "" {
+			problems = append(problems, fmt.Sprintf("auth.jwt.private_key_file is required for %s", c.Auth.JWT.Algorithm))
+		}
+	}
+
+	if _, err := middleware.ParseTokenLookup(c.Auth.Tokens.Lookup); err != nil {
+		problems = append(problems, fmt.Sprintf("auth.tokens.lookup: %v", err))
+	}
+
+	if c.Auth.MFA.EncryptionKey == "" {
+		problems = append(problems, "auth.mfa.encryption_key is required, generate one with openssl rand -base64 32 and set TOTP_ENCRYPTION_KEY")
+	} else if key, err := base64.StdEncoding.DecodeString(c.Auth.MFA.EncryptionKey); err != nil || len(key) != 32 {
+		problems = append(problems, "auth.mfa.encryption_key must be 32 bytes, base64 encoded")
+	}
+
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.SMTP.Host == "" {
+			problems = append(problems, "mail.smtp.host is required for the smtp driver")
+		}
+	case "file":
+		if c.Mail.Dir == "" {
+			problems = append(problems, "mail.dir is required for the file driver")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+func describeFieldError(err validator.FieldError) string {
+	// drop the leading "Config."
+	path := err.Namespace()
+	path = path[strings.Index(path, ".")+1:]
+
+	switch err.Tag() {
+	case "required":
+		return path + " is required"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s, got %q", path, strings.ReplaceAll(err.Param(), " ", ", "), err.Value())
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s", path, err.Param())
+	case "max", "lte":
+		return fmt.Sprintf("%s must be at most %s", path, err.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", path, err.Param())
+	case "gtefield":
+		// the param is the Go name of a sibling field
+		sibling := path[:strings.LastIndex(path, ".")+1] + snakeCase(err.Param())
+		return fmt.Sprintf("%s must not be lower than %s", path, sibling)
+	case "url":
+		return path + " must be a URL"
+	case "ip|cidr":
+		return fmt.Sprintf("%s must be an IP address or CIDR range, got %q", path, err.Value())
+	default:
+		return fmt.Sprintf("%s failed the %s check", path, err.Tag())
+	}
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
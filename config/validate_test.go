@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// validConfig is the defaults completed with the settings that have none.
+func validConfig() Config {
+	config := Defaults()
+	config.Database.User = "app"
+	config.Database.Name = "app"
+	config.Auth.JWT.SecretKey = "secret"
+	config.Auth.MFA.EncryptionKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	config.Mail.SMTP.Host = "smtp.example.com"
+	return config
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			change: func(c *Config) {},
+		},
+		{
+			name: "required",
+			change: func(c *Config) {
+				c.Database.Name = ""
+				c.Auth.MFA.EncryptionKey = ""
+			},
+			want: []string{"auth.mfa.encryption_key is required, generate one with openssl rand -base64 32", "database.name is required"},
+		},
+		{
+			name:   "oneof",
+			change: func(c *Config) { c.Database.SSLMode = "sometimes" },
+			want:   []string{`database.ssl_mode must be one of disable, allow, prefer, require, verify-ca, verify-full, got "sometimes"`},
+		},
+		{
+			name: "bounds",
+			change: func(c *Config) {
+				c.Server.Port = 70000
+				c.Auth.Password.BcryptCost = 3
+				c.Auth.Tokens.AccessTTL = 0
+			},
+			want: []string{
+				"auth.password.bcrypt_cost must be at least 4",
+				"auth.tokens.access_ttl must be greater than 0",
+				"server.port must be at most 65535",
+			},
+		},
+		{
+			name:   "sibling field",
+			change: func(c *Config) { c.Auth.Password.MaxLength = 4 },
+			want:   []string{"auth.password.max_length must not be lower than auth.password.min_length"},
+		},
+		{
+			name:   "url",
+			change: func(c *Config) { c.Auth.Email.PasswordResetURL = "not a url" },
+			want:   []string{"auth.email.password_reset_url must be a URL"},
+		},
+		{
+			name: "proxy header without trusted proxies",
+			change: func(c *Config) {
+				c.Server.ProxyHeader = "X-Forwarded-For"
+			},
+			want: []string{"server.trusted_proxies is required with server.proxy_header"},
+		},
+		{
+			name: "invalid trusted proxy",
+			change: func(c *Config) {
+				c.Server.ProxyHeader = "X-Forwarded-For"
+				c.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"}
+			},
+			want: []string{`server.trusted_proxies[1] must be an IP address or CIDR range, got "proxy.local"`},
+		},
+		{
+			name:   "insecure SameSite None cookie",
+			change: func(c *Config) { c.Auth.Cookies.SameSite = "None" },
+			want:   []string{"auth.cookies.same_site None requires auth.cookies.secure"},
+		},
+		{
+			name:   "HS256 without a secret",
+			change: func(c *Config) { c.Auth.JWT.SecretKey = "" },
+			want:   []string{"auth.jwt.secret_key is required for HS256"},
+		},
+		{
+			name:   "EdDSA without a key file",
+			change: func(c *Config) { c.Auth.JWT.Algorithm = "EdDSA" },
+			want:   []string{"auth.jwt.private_key_file is required for EdDSA"},
+		},
+		{
+			name:   "token lookup",
+			change: func(c *Config) { c.Auth.Tokens.Lookup = "body:token" },
+			want:   []string{"auth.tokens.lookup: "},
+		},
+		{
+			name:   "short encryption key",
+			change: func(c *Config) { c.Auth.MFA.EncryptionKey = base64.StdEncoding.EncodeToString(make([]byte, 16)) },
+			want:   []string{"auth.mfa.encryption_key must be 32 bytes, base64 encoded"},
+		},
+		{
+			name:   "smtp without a host",
+			change: func(c *Config) { c.Mail.SMTP.Host = "" },
+			want:   []string{"mail.smtp.host is required for the smtp driver"},
+		},
+		{
+			name: "file driver without a directory",
+			change: func(c *Config) {
+				c.Mail.Driver = "file"
+				c.Mail.Dir = ""
+			},
+			want: []string{"mail.dir is required for the file driver"},
+		},
+		{
+			name: "incomplete OIDC provider",
+			change: func(c *Config) {
+				c.Auth.OIDC.Providers = map[string]OIDCProviderConfig{
+					"google": {Issuer: "https://accounts.google.com"},
+				}
+			},
+			want: []string{
+				"auth.oidc.providers[google].client_id is required",
+				"auth.oidc.providers[google].redirect_url is required",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := validConfig()
+			test.change(&config)
+
+			err := config.Validate()
+
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate passed, want %q", test.want)
+			}
+
+			problems := strings.Split(strings.TrimPrefix(err.Error(), "invalid configuration:\n  "), "\n  ")
+
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems = %q, want %q", problems, test.want)
+			}
+
+			for i, want := range test.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
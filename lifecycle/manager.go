@@ -0,0 +1,112 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Config struct {
+	// ShutdownTimeout bounds both draining in-flight requests and running the
+	// shutdown hooks
+	ShutdownTimeout time.Duration
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager serves the app until SIGINT or SIGTERM, then drains it and runs the
+// shutdown hooks in reverse order of registration, so whatever started last
+// is stopped first.
+type Manager interface {
+	OnShutdown(name string, stop func(ctx context.Context) error)
+	Run(app *fiber.App, addr string) error
+}
+
+type implManager struct {
+	config Config
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+func NewManager(config Config) Manager {
+	return &implManager{
+		config: config,
+	}
+}
+
+func (m *implManager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+func (m *implManager) Run(app *fiber.App, addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+
+	go func() {
+		listenErr <- app.Listen(addr)
+	}()
+
+	var err error
+
+	select {
+	case err = <-listenErr:
+		// the server never started or died on its own, there is nothing to drain
+	case <-ctx.Done():
+		// a second signal kills the process the default way
+		stop()
+
+		log.Printf("shutting down, draining requests for up to %s", m.config.ShutdownTimeout)
+
+		if shutdownErr := app.ShutdownWithTimeout(m.config.ShutdownTimeout); shutdownErr != nil {
+			log.Printf("failed to drain requests: %v", shutdownErr)
+		}
+
+		err = <-listenErr
+	}
+
+	if hookErr := m.shutdown(); hookErr != nil {
+		err = errors.Join(err, hookErr)
+	}
+
+	return err
+}
+
+func (m *implManager) shutdown() error {
+	m.mu.Lock()
+	hooks := append([]hook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].stop(ctx); err != nil {
+			log.Printf("shutdown hook %s failed: %v", hooks[i].name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", hooks[i].name, err))
+			continue
+		}
+
+		log.Printf("stopped %s", hooks[i].name)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// implFileMailer writes every message to its own .eml file so local
+// development and tests can read what would have been sent.
+type implFileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) Mailer {
+	return &implFileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *implFileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(msg.To))
+
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o600)
+}
+
+// implLogMailer only logs who a message is for. Bodies carry reset and
+// verification links, which must not end up in the logs.
+type implLogMailer struct {
+	logger *log.Logger
+}
+
+func NewLogMailer(logger *log.Logger) Mailer {
+	return &implLogMailer{
+		logger: logger,
+	}
+}
+
+func (m *implLogMailer) Send(msg Message) error {
+	m.logger.Printf("mail to=%s subject=%q (body not logged, use the file driver to read it)", msg.To, msg.Subject)
+	return nil
+}
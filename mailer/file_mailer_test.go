@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerLeavesOutBody(t *testing.T) {
+	var out bytes.Buffer
+
+	err := NewLogMailer(log.New(&out, "", 0)).Send(Message{
+		To:      "jane@example.com",
+		Subject: "Reset your password",
+		Body:    "https://app.example.com/reset?token=secret-token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(out.String(), "secret-token") {
+		t.Errorf("log contains the body: %s", out.String())
+	}
+
+	if !strings.Contains(out.String(), "jane@example.com") {
+		t.Errorf("log is missing the recipient: %s", out.String())
+	}
+}
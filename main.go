@@ -1,19 +1,14 @@
 package main
 
 import (
+	"log"
 	"os"
 
-	"app/config"
+	"app/cli"
 )
 
 func main() {
-	app := config.NewAppConfig()
-
-	var port string
-	
-	if port = os.Getenv("PORT"); port == "" {
-		port = "8080"
+	if err := cli.Run(os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
-
-	app.Listen(":" + port)
-}
\ No newline at end of file
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"app/auth"
+	"app/migrations/dbtest"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type staticPermissions map[string]bool
+
+func (p staticPermissions) Permissions(roles []string) (map[string]bool, error) {
+	return p, nil
+}
+
+func newTestMiddleware() *implMiddleware {
+	return NewMiddleware(nil, nil, nil, staticPermissions{"product:create": true, "product:update": true}, nil).(*implMiddleware)
+}
+
+func ok(c *fiber.Ctx) error {
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func TestRequirePermissionScopes(t *testing.T) {
+	m := newTestMiddleware()
+
+	tests := []struct {
+		name        string
+		scopes      []string
+		permissions []string
+		want        int
+	}{
+		{"token with permission", nil, []string{"product:create"}, fiber.StatusOK},
+		{"token without permission", nil, []string{"user:manage"}, fiber.StatusForbidden},
+		{"key scoped to permission", []string{"product:create"}, []string{"product:create"}, fiber.StatusOK},
+		{"key scoped elsewhere", []string{"product:update"}, []string{"product:create"}, fiber.StatusForbidden},
+		{"key with scope the role lacks", []string{"user:manage"}, []string{"user:manage"}, fiber.StatusForbidden},
+		{"key on guard without permissions", []string{"product:create"}, nil, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+
+			app.Get("/", func(c *fiber.Ctx) error {
+				if test.scopes != nil {
+					c.Locals("scopes", test.scopes)
+					c.Locals("auth_source", "api_key")
+				}
+				return c.Next()
+			}, m.RequirePermission(test.permissions...), ok)
+
+			res, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAPIKeys(t *testing.T) {
+	m := newTestMiddleware()
+
+	tests := []struct {
+		name   string
+		role   int
+		scopes []string
+		want   int
+	}{
+		{"token with the role", model.RoleAdmin, nil, fiber.StatusOK},
+		{"token without the role", model.RoleUser, nil, fiber.StatusForbidden},
+		{"key scoped to the whole role", model.RoleAdmin, []string{"product:create", "product:update", "audit:read"}, fiber.StatusOK},
+		{"key scoped to part of the role", model.RoleAdmin, []string{"product:create"}, fiber.StatusForbidden},
+		{"key without scopes", model.RoleAdmin, []string{}, fiber.StatusForbidden},
+		{"key of an owner without the role", model.RoleUser, []string{"product:create", "product:update"}, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+
+			app.Get("/", func(c *fiber.Ctx) error {
+				c.Locals("role", test.role)
+				if test.scopes != nil {
+					c.Locals("scopes", test.scopes)
+					c.Locals("auth_source", "api_key")
+				}
+				return c.Next()
+			}, m.Authorize(model.RoleAdmin), ok)
+
+			res, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		modify func(db *gorm.DB, key *model.ApiKey, user *model.User)
+		header func(key string) string
+		path   string
+		want   int
+	}{
+		{name: "key on a scoped route", path: "/scoped", want: fiber.StatusOK},
+		{name: "key on a route that does not allow keys", path: "/plain", want: fiber.StatusForbidden},
+		{name: "keys allowed after authenticating", path: "/late", want: fiber.StatusForbidden},
+		{name: "malformed key", header: func(string) string { return "not-a-key" }, path: "/scoped", want: fiber.StatusUnauthorized},
+		{name: "wrong secret", header: func(key string) string { return key + "x" }, path: "/scoped", want: fiber.StatusUnauthorized},
+		{
+			name:   "revoked key",
+			modify: func(db *gorm.DB, key *model.ApiKey, user *model.User) { db.Model(key).Update("revoked_at", past) },
+			path:   "/scoped",
+			want:   fiber.StatusUnauthorized,
+		},
+		{
+			name:   "expired key",
+			modify: func(db *gorm.DB, key *model.ApiKey, user *model.User) { db.Model(key).Update("expires_at", past) },
+			path:   "/scoped",
+			want:   fiber.StatusUnauthorized,
+		},
+		{
+			name:   "suspended owner",
+			modify: func(db *gorm.DB, key *model.ApiKey, user *model.User) { db.Model(user).Update("suspended_at", past) },
+			path:   "/scoped",
+			want:   fiber.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := dbtest.New(t)
+
+			user := &model.User{Username: "keys", Email: "keys@example.com", Password: "x"}
+			if err := db.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			prefix, key, secretHash, err := auth.GenerateAPIKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			apiKey := &model.ApiKey{UserID: user.ID, Name: "test", Prefix: prefix, SecretHash: secretHash, Scopes: "product:create"}
+			if err := db.Create(apiKey).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if test.modify != nil {
+				test.modify(db, apiKey, user)
+			}
+
+			m := NewMiddleware(db, nil, nil, staticPermissions{"product:create": true}, nil)
+
+			app := fiber.New()
+			app.Get("/scoped", m.AllowAPIKey, m.Authenticate, m.RequirePermission("product:create"), ok)
+			app.Get("/plain", m.Authenticate, m.RequirePermission("product:create"), ok)
+			app.Get("/late", m.Authenticate, m.AllowAPIKey, m.RequirePermission("product:create"), ok)
+
+			header := key
+			if test.header != nil {
+				header = test.header(key)
+			}
+
+			req := httptest.NewRequest("GET", test.path, nil)
+			req.Header.Set(apiKeyHeader, header)
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
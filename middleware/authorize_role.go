@@ -6,19 +6,47 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// legacyRoleNames are the named roles SeedRoles gives the numeric ones.
+var legacyRoleNames = map[int]string{
+	model.RoleUser:  "user",
+	model.RoleAdmin: "admin",
+}
+
+// Authorize lets the request through when the user has one of the roles. A
+// request made with an API key holds the owner's role only as far as the key
+// is scoped to it, so the key needs every permission of the role.
 func (m *implMiddleware) Authorize(allowedRoles ...int) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		user, ok := c.Locals("user").(*model.User)
+		// the role travels in the access token, so GetCredential is not needed here
+		role, ok := c.Locals("role").(int)
+
+		if user, found := c.Locals("user").(*model.User); !ok && found {
+			role, ok = user.Role, true
+		}
+
 		if !ok {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"message": "Access denied",
 			})
 		}
 
-		for _, role := range allowedRoles {
-			if user.Role == role {
-				return c.Next()
+		for _, allowed := range allowedRoles {
+			if role != allowed {
+				continue
 			}
+
+			scoped, err := m.scopesCoverRole(c, role)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Database error",
+				})
+			}
+
+			if !scoped {
+				break
+			}
+
+			return c.Next()
 		}
 
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
@@ -26,3 +54,29 @@ func (m *implMiddleware) Authorize(allowedRoles ...int) fiber.Handler {
 		})
 	}
 }
+
+// scopesCoverRole reports whether the key the request was made with, if any,
+// is scoped to every permission of the role.
+func (m *implMiddleware) scopesCoverRole(c *fiber.Ctx, role int) (bool, error) {
+	if _, scoped := c.Locals("scopes").([]string); !scoped {
+		return true, nil
+	}
+
+	name, known := legacyRoleNames[role]
+	if !known {
+		return false, nil
+	}
+
+	granted, err := m.permissions.Permissions([]string{name})
+	if err != nil {
+		return false, err
+	}
+
+	for permission, ok := range granted {
+		if ok && !scopeAllows(c, permission) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
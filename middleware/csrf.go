@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"app/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// validCSRF reports whether a request authenticated by cookie may go ahead.
+// Safe methods are always allowed, everything else has to echo the csrf_token
+// cookie in the X-CSRF-Token header.
+func validCSRF(c *fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	}
+
+	cookie := c.Cookies(auth.CSRFCookieName)
+	header := c.Get(auth.CSRFHeaderName)
+
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"app/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestCSRF(t *testing.T) {
+	keys, err := auth.NewKeyManager(auth.KeyConfig{Algorithm: "HS256", SecretKey: []byte("test-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	token, err := keys.Sign(auth.TokenTypeAccess, &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token",
+			Subject:   "user",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extractors, err := ParseTokenLookup("header:Authorization,cookie:token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMiddleware(nil, auth.NewTokenVerifier(keys, auth.ClaimsConfig{}), auth.NewMemoryRevocationStore(), staticPermissions{}, extractors)
+
+	app := fiber.New()
+	app.All("/", m.Authenticate, ok)
+
+	tests := []struct {
+		name   string
+		method string
+		bearer bool
+		cookie string
+		header string
+		want   int
+	}{
+		{"cookie on a safe method", fiber.MethodGet, false, "", "", fiber.StatusOK},
+		{"cookie with matching header", fiber.MethodPost, false, "csrf-value", "csrf-value", fiber.StatusOK},
+		{"cookie without header", fiber.MethodPost, false, "csrf-value", "", fiber.StatusForbidden},
+		{"cookie with wrong header", fiber.MethodDelete, false, "csrf-value", "other-value", fiber.StatusForbidden},
+		{"header without csrf cookie", fiber.MethodPut, false, "", "csrf-value", fiber.StatusForbidden},
+		{"empty cookie and header", fiber.MethodPatch, false, "", "", fiber.StatusForbidden},
+		{"bearer token needs no csrf token", fiber.MethodPost, true, "", "", fiber.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/", nil)
+
+			if test.bearer {
+				req.Header.Set("Authorization", "Bearer "+token)
+			} else {
+				req.Header.Add("Cookie", "token="+token)
+			}
+
+			if test.cookie != "" {
+				req.Header.Add("Cookie", auth.CSRFCookieName+"="+test.cookie)
+			}
+
+			if test.header != "" {
+				req.Header.Set(auth.CSRFHeaderName, test.header)
+			}
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"app/auth"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
@@ -8,15 +10,26 @@ import (
 type Middleware interface {
 	Authenticate(c *fiber.Ctx) error
 	Authorize(allowedRoles ...int) func(*fiber.Ctx) error
+	RequirePermission(permissions ...string) func(*fiber.Ctx) error
+	RequireOwnership(resource interface{}, ownerScope string, bypassPermission string) func(*fiber.Ctx) error
 	GetCredential(c *fiber.Ctx) error
+	AllowAPIKey(c *fiber.Ctx) error
 }
 
 type implMiddleware struct {
-	db *gorm.DB
+	db          *gorm.DB
+	verifier    auth.TokenVerifier
+	revocations auth.RevocationStore
+	permissions auth.PermissionResolver
+	extractors  []TokenExtractor
 }
 
-func NewMiddleware(db *gorm.DB) Middleware {
+func NewMiddleware(db *gorm.DB, verifier auth.TokenVerifier, revocations auth.RevocationStore, permissions auth.PermissionResolver, extractors []TokenExtractor) Middleware {
 	return &implMiddleware{
-		db: db,
+		db:          db,
+		verifier:    verifier,
+		revocations: revocations,
+		permissions: permissions,
+		extractors:  extractors,
 	}
 }
@@ -1,15 +1,19 @@
 package middleware
 
 import (
-	"fmt"
-	"os"
+	"errors"
+
+	"app/auth"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
 )
 
 func (m *implMiddleware) Authenticate(c *fiber.Ctx) error {
-	userToken := c.Cookies("token")
+	if key := c.Get(apiKeyHeader); key != "" {
+		return m.authenticateAPIKey(c, key)
+	}
+
+	userToken, source := m.extractToken(c)
 
 	if userToken == "" {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -17,32 +21,45 @@ func (m *implMiddleware) Authenticate(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := jwt.Parse(userToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
-	})
-
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "your session has expired",
+	// browsers attach cookies to cross-site requests, headers and API keys they do not
+	if source == "cookie" && !validCSRF(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "invalid csrf token",
 		})
 	}
 
-	if tokenValue, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := tokenValue["user_id"].(string)
-		if !ok {
+	claims, err := m.verifier.Verify(userToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenExpired) {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"message": "unexpected value",
+				"message": "your session has expired",
 			})
 		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "invalid session",
+		})
+	}
+
+	revoked, err := m.revocations.IsRevoked(claims.ID, claims.Subject, claims.IssuedAt.Time)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database error",
+		})
+	}
 
-		c.Locals("user_id", userID)
-		return c.Next()
+	if revoked {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "your session has been revoked",
+		})
 	}
 
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-		"message": "invalid session",
-	})
+	c.Locals("claims", claims)
+	c.Locals("user_id", claims.Subject)
+	c.Locals("role", claims.Role)
+	c.Locals("roles", claims.Roles)
+	c.Locals("auth_source", source)
+	c.Locals("token_id", claims.ID)
+	c.Locals("session_id", claims.SessionID)
+	c.Locals("token_expires_at", claims.ExpiresAt.Time)
+	return c.Next()
 }
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type resourceOwner struct {
+	CreatedByID *string
+}
+
+// RequireOwnership only lets the creator of the resource identified by the
+// ":id" route param through, unless the caller holds the bypass permission.
+// The resource must be a model with a CreatedByID column, e.g. &model.Product{}.
+// An API key reaches its owner's resources when it is scoped to ownerScope,
+// e.g. "product:update:own", and others only with the bypass permission.
+func (m *implMiddleware) RequireOwnership(resource interface{}, ownerScope string, bypassPermission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		owner := &resourceOwner{}
+
+		if err := m.db.Model(resource).Select("created_by_id").Where("id = ?", c.Params("id")).Take(owner).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"message": "Resource not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Database error",
+			})
+		}
+
+		userID, _ := c.Locals("user_id").(string)
+
+		if owner.CreatedByID != nil && *owner.CreatedByID == userID && scopeAllows(c, ownerScope) {
+			return c.Next()
+		}
+
+		granted, err := m.hasPermissions(c, bypassPermission)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Database error",
+			})
+		}
+
+		if !granted {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Access denied",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"app/migrations/dbtest"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireOwnership(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   bool
+		granted staticPermissions
+		scopes  []string
+		want    int
+	}{
+		{"owner with a token", true, staticPermissions{}, nil, fiber.StatusOK},
+		{"other user with a token", false, staticPermissions{}, nil, fiber.StatusForbidden},
+		{"other user with the bypass permission", false, staticPermissions{"category:update": true}, nil, fiber.StatusOK},
+		{"owner with a key scoped to own resources", true, staticPermissions{}, []string{"category:update:own"}, fiber.StatusOK},
+		{"owner with a key scoped elsewhere", true, staticPermissions{}, []string{"category:create"}, fiber.StatusForbidden},
+		{"other user with a key scoped to own resources", false, staticPermissions{}, []string{"category:update:own"}, fiber.StatusForbidden},
+		{"bypass permission the key is scoped to", false, staticPermissions{"category:update": true}, []string{"category:update"}, fiber.StatusOK},
+		{"bypass permission the key is not scoped to", false, staticPermissions{"category:update": true}, []string{"category:update:own"}, fiber.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := dbtest.New(t)
+
+			owner := &model.User{Username: "owner", Email: "owner@example.com", Password: "x"}
+			if err := db.Create(owner).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			category := &model.Category{Name: "owned", CreatedByID: &owner.ID}
+			if err := db.Create(category).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			userID := "00000000-0000-0000-0000-000000000000"
+			if test.owner {
+				userID = owner.ID
+			}
+
+			m := NewMiddleware(db, nil, nil, test.granted, nil)
+
+			app := fiber.New()
+			app.Put("/:id", func(c *fiber.Ctx) error {
+				c.Locals("user_id", userID)
+				if test.scopes != nil {
+					c.Locals("scopes", test.scopes)
+					c.Locals("auth_source", "api_key")
+				}
+				return c.Next()
+			}, m.RequireOwnership(&model.Category{}, "category:update:own", "category:update"), ok)
+
+			res, err := app.Test(httptest.NewRequest("PUT", fmt.Sprintf("/%d", category.ID), nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
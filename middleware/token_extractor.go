@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TokenExtractor struct {
+	Source  string
+	Extract func(c *fiber.Ctx) string
+}
+
+func FromAuthHeader(header string) TokenExtractor {
+	return TokenExtractor{
+		Source: "header",
+		Extract: func(c *fiber.Ctx) string {
+			scheme, token, found := strings.Cut(strings.TrimSpace(c.Get(header)), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				return ""
+			}
+			return strings.TrimSpace(token)
+		},
+	}
+}
+
+func FromCookie(name string) TokenExtractor {
+	return TokenExtractor{
+		Source: "cookie",
+		Extract: func(c *fiber.Ctx) string {
+			return c.Cookies(name)
+		},
+	}
+}
+
+func FromQuery(param string) TokenExtractor {
+	return TokenExtractor{
+		Source: "query",
+		Extract: func(c *fiber.Ctx) string {
+			return c.Query(param)
+		},
+	}
+}
+
+// ParseTokenLookup builds extractors from a comma separated list of
+// "source:name" pairs, e.g. "header:Authorization,cookie:token,query:access_token".
+// The order of the list is the order the extractors are tried in.
+func ParseTokenLookup(lookup string) ([]TokenExtractor, error) {
+	extractors := make([]TokenExtractor, 0)
+
+	for _, part := range strings.Split(lookup, ",") {
+		source, name, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid token lookup %q", part)
+		}
+
+		switch source {
+		case "header":
+			extractors = append(extractors, FromAuthHeader(name))
+		case "cookie":
+			extractors = append(extractors, FromCookie(name))
+		case "query":
+			extractors = append(extractors, FromQuery(name))
+		default:
+			return nil, fmt.Errorf("unknown token source %q", source)
+		}
+	}
+
+	return extractors, nil
+}
+
+func (m *implMiddleware) extractToken(c *fiber.Ctx) (string, string) {
+	for _, extractor := range m.extractors {
+		if token := extractor.Extract(c); token != "" {
+			return token, extractor.Source
+		}
+	}
+	return "", ""
+}
@@ -7,6 +7,13 @@ import (
 	"gorm.io/gorm"
 )
 
+// credentialColumns are the user columns loaded into the "user" local. The
+// password and TOTP secret are left out on purpose.
+var credentialColumns = []string{
+	"id", "username", "email", "phone_number", "role", "created_at",
+	"email_verified_at", "pending_email", "totp_enabled_at", "suspended_at",
+}
+
 func (m *implMiddleware) GetCredential(c *fiber.Ctx) error {
 	user := &model.User{}
 
@@ -18,7 +25,7 @@ func (m *implMiddleware) GetCredential(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := m.db.Select("id", "username", "email", "phone_number").Where("id = ?", id).First(user).Error; err != nil {
+	if err := m.db.Select(credentialColumns).Where("id = ?", id).First(user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"message": "Invalid session",
@@ -29,6 +36,12 @@ func (m *implMiddleware) GetCredential(c *fiber.Ctx) error {
 		})
 	}
 
+	if user.SuspendedAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Account is suspended",
+		})
+	}
+
 	c.Locals("user", user)
 	return c.Next()
 }
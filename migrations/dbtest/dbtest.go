@@ -0,0 +1,70 @@
+// Package dbtest gives tests the Postgres database in TEST_DATABASE_URL with
+// every migration applied. Tests that ask for it are skipped when the variable
+// is not set.
+package dbtest
+
+import (
+	"os"
+	"testing"
+
+	"app/migrations"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// URL returns TEST_DATABASE_URL or skips the test.
+func URL(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	return dsn
+}
+
+// Open connects to the test database without migrating it and closes the
+// connection when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.Open(URL(t)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// New migrates the test database and returns a transaction that is rolled
+// back when the test ends.
+func New(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db := Open(t)
+
+	all, err := migrations.Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrations.NewMigrator(db, all).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
@@ -0,0 +1,171 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int64
+		wantErr string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"0010_later.up.sql":    file("up 10"),
+				"0010_later.down.sql":  file("down 10"),
+				"0002_second.up.sql":   file("up 2"),
+				"0002_second.down.sql": file("down 2"),
+				"0001_first.up.sql":    file("up 1"),
+				"0001_first.down.sql":  file("down 1"),
+			},
+			want: []int64{1, 2, 10},
+		},
+		{
+			name:  "no migrations",
+			files: fstest.MapFS{},
+			want:  []int64{},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"0001_first.up.sql": file("up 1"),
+			},
+			wantErr: "migration 1_first needs both an up and a down file",
+		},
+		{
+			name: "missing up file",
+			files: fstest.MapFS{
+				"0001_first.down.sql": file("down 1"),
+			},
+			wantErr: "needs both an up and a down file",
+		},
+		{
+			name: "empty up file",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   file(""),
+				"0001_first.down.sql": file("down 1"),
+			},
+			wantErr: "needs both an up and a down file",
+		},
+		{
+			name: "bad file name",
+			files: fstest.MapFS{
+				"0001-first.up.sql":   file("up 1"),
+				"0001-first.down.sql": file("down 1"),
+			},
+			wantErr: `unexpected migration file "0001-first.down.sql"`,
+		},
+		{
+			name: "other file in the directory",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   file("up 1"),
+				"0001_first.down.sql": file("down 1"),
+				"README.md":           file("notes"),
+			},
+			wantErr: `unexpected migration file "README.md"`,
+		},
+		{
+			name: "subdirectory",
+			files: fstest.MapFS{
+				"0001_first.up.sql/nested.sql": file("up 1"),
+			},
+			wantErr: `unexpected migration file "0001_first.up.sql"`,
+		},
+		{
+			name: "names differ within a version",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   file("up 1"),
+				"0001_other.down.sql": file("down 1"),
+			},
+			wantErr: "migration 1 has files with different names",
+		},
+		{
+			name: "version out of range",
+			files: fstest.MapFS{
+				"99999999999999999999_huge.up.sql":   file("up"),
+				"99999999999999999999_huge.down.sql": file("down"),
+			},
+			wantErr: "invalid migration version",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations, err := Load(test.files)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want one about %q", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			versions := make([]int64, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+
+				if migration.Up == "" || migration.Down == "" {
+					t.Errorf("migration %d = %+v, want both files read", migration.Version, migration)
+				}
+			}
+
+			if len(versions) != len(test.want) {
+				t.Fatalf("versions = %v, want %v", versions, test.want)
+			}
+
+			for i := range versions {
+				if versions[i] != test.want[i] {
+					t.Fatalf("versions = %v, want %v", versions, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadReadsContent(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0001_create_things.up.sql":   file("CREATE TABLE things ();"),
+		"0001_create_things.down.sql": file("DROP TABLE things;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Migration{Version: 1, Name: "create_things", Up: "CREATE TABLE things ();", Down: "DROP TABLE things;"}
+
+	if len(migrations) != 1 || migrations[0] != want {
+		t.Errorf("migrations = %+v, want [%+v]", migrations, want)
+	}
+}
+
+// TestEmbedded keeps the shipped files loadable, the app refuses to boot
+// otherwise.
+func TestEmbedded(t *testing.T) {
+	migrations, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d_%s is numbered out of sequence, want %d", migration.Version, migration.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey is the pg_advisory_lock key held while migrating, so instances
+// booting at the same time run the migrations once.
+const lockKey int64 = 7283946510
+
+var ErrNothingToRollback = errors.New("no migration to roll back")
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Missing marks a version recorded in the database without a file
+	Missing bool
+}
+
+type Migrator interface {
+	// Up applies up to steps pending migrations, all of them when steps is 0.
+	Up(steps int) ([]Migration, error)
+	// Down rolls back the last steps applied migrations.
+	Down(steps int) ([]Migration, error)
+	// Redo rolls back the last applied migration and applies it again.
+	Redo() (*Migration, error)
+	Status() ([]Status, error)
+}
+
+type appliedMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type implMigrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB, migrations []Migration) Migrator {
+	return &implMigrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+func (m *implMigrator) Up(steps int) ([]Migration, error) {
+	applied := make([]Migration, 0)
+
+	err := m.locked(func(conn *gorm.DB) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(conn, migration); err != nil {
+				return err
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+func (m *implMigrator) Down(steps int) ([]Migration, error) {
+	if steps < 1 {
+		steps = 1
+	}
+
+	rolledBack := make([]Migration, 0)
+
+	err := m.locked(func(conn *gorm.DB) error {
+		for len(rolledBack) < steps {
+			migration, err := m.last(conn)
+			if errors.Is(err, ErrNothingToRollback) && len(rolledBack) > 0 {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := m.rollback(conn, *migration); err != nil {
+				return err
+			}
+
+			rolledBack = append(rolledBack, *migration)
+		}
+
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+func (m *implMigrator) Redo() (*Migration, error) {
+	var redone *Migration
+
+	err := m.locked(func(conn *gorm.DB) error {
+		migration, err := m.last(conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.rollback(conn, *migration); err != nil {
+			return err
+		}
+
+		if err := m.apply(conn, *migration); err != nil {
+			return err
+		}
+
+		redone = migration
+
+		return nil
+	})
+
+	return redone, err
+}
+
+func (m *implMigrator) Status() ([]Status, error) {
+	if err := m.db.AutoMigrate(&appliedMigration{}); err != nil {
+		return nil, err
+	}
+
+	versions, err := m.appliedVersions(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+
+		if row, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(versions, migration.Version)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for _, row := range versions {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the advisory lock. Session
+// level locks belong to a connection, so the pool must not hand fn another one.
+func (m *implMigrator) locked(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err != nil {
+				log.Printf("failed to release migration lock: %v", err)
+			}
+		}()
+
+		if err := conn.AutoMigrate(&appliedMigration{}); err != nil {
+			return err
+		}
+
+		return fn(conn)
+	})
+}
+
+func (m *implMigrator) appliedVersions(conn *gorm.DB) (map[int64]appliedMigration, error) {
+	rows := make([]appliedMigration, 0)
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row
+	}
+
+	return versions, nil
+}
+
+// last returns the most recently applied migration.
+func (m *implMigrator) last(conn *gorm.DB) (*Migration, error) {
+	row := &appliedMigration{}
+
+	err := conn.Order("version DESC").Limit(1).Find(row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if row.Version == 0 {
+		return nil, ErrNothingToRollback
+	}
+
+	for i := range m.migrations {
+		if m.migrations[i].Version == row.Version {
+			return &m.migrations[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("applied migration %d_%s has no file to roll back with", row.Version, row.Name)
+}
+
+// apply and rollback run the statements and the bookkeeping in one
+// transaction, so a failing migration leaves nothing behind.
+func (m *implMigrator) apply(conn *gorm.DB, migration Migration) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&appliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Printf("applied migration %d_%s", migration.Version, migration.Name)
+
+	return nil
+}
+
+func (m *implMigrator) rollback(conn *gorm.DB, migration Migration) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("version = ?", migration.Version).Delete(&appliedMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	log.Printf("rolled back migration %d_%s", migration.Version, migration.Name)
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testSchemaDB connects to TEST_DATABASE_URL with a fresh schema first on the
+// search path, so the migrator's bookkeeping never touches the shared one.
+// dbtest cannot be used here, it imports this package.
+func testSchemaDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// search_path is a session setting, every query must use this connection
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+
+	schema := fmt.Sprintf("migrator_test_%d", time.Now().UnixNano())
+
+	if err := db.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB.Close()
+	})
+
+	if err := db.Exec("SET search_path TO " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_alpha", Up: "CREATE TABLE alpha (id int)", Down: "DROP TABLE alpha"},
+	{Version: 2, Name: "create_beta", Up: "CREATE TABLE beta (id int)", Down: "DROP TABLE beta"},
+	{Version: 3, Name: "create_gamma", Up: "CREATE TABLE gamma (id int)", Down: "DROP TABLE gamma"},
+}
+
+func tableExists(t *testing.T, db *gorm.DB, table string) bool {
+	t.Helper()
+
+	var exists bool
+	if err := db.Raw("SELECT to_regclass(?) IS NOT NULL", table).Scan(&exists).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return exists
+}
+
+func versionsOf(migrations []Migration) []int64 {
+	versions := make([]int64, 0, len(migrations))
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+// TestMigrator runs the steps in order against one schema, each starting
+// where the previous one left off.
+func TestMigrator(t *testing.T) {
+	db := testSchemaDB(t)
+	migrator := NewMigrator(db, testMigrations)
+
+	tests := []struct {
+		name       string
+		run        func() ([]int64, error)
+		want       []int64
+		wantErr    error
+		wantTables map[string]bool
+	}{
+		{
+			name: "up one step",
+			run: func() ([]int64, error) {
+				applied, err := migrator.Up(1)
+				return versionsOf(applied), err
+			},
+			want:       []int64{1},
+			wantTables: map[string]bool{"alpha": true, "beta": false, "gamma": false},
+		},
+		{
+			name: "up the rest",
+			run: func() ([]int64, error) {
+				applied, err := migrator.Up(0)
+				return versionsOf(applied), err
+			},
+			want:       []int64{2, 3},
+			wantTables: map[string]bool{"alpha": true, "beta": true, "gamma": true},
+		},
+		{
+			name: "up with nothing pending",
+			run: func() ([]int64, error) {
+				applied, err := migrator.Up(0)
+				return versionsOf(applied), err
+			},
+			want:       []int64{},
+			wantTables: map[string]bool{"alpha": true, "beta": true, "gamma": true},
+		},
+		{
+			name: "redo the last",
+			run: func() ([]int64, error) {
+				redone, err := migrator.Redo()
+				if err != nil {
+					return nil, err
+				}
+				return []int64{redone.Version}, nil
+			},
+			want:       []int64{3},
+			wantTables: map[string]bool{"alpha": true, "beta": true, "gamma": true},
+		},
+		{
+			name: "down two steps",
+			run: func() ([]int64, error) {
+				rolledBack, err := migrator.Down(2)
+				return versionsOf(rolledBack), err
+			},
+			want:       []int64{3, 2},
+			wantTables: map[string]bool{"alpha": true, "beta": false, "gamma": false},
+		},
+		{
+			name: "down more steps than applied",
+			run: func() ([]int64, error) {
+				rolledBack, err := migrator.Down(5)
+				return versionsOf(rolledBack), err
+			},
+			want:       []int64{1},
+			wantTables: map[string]bool{"alpha": false},
+		},
+		{
+			name: "down with nothing applied",
+			run: func() ([]int64, error) {
+				rolledBack, err := migrator.Down(1)
+				return versionsOf(rolledBack), err
+			},
+			wantErr: ErrNothingToRollback,
+		},
+		{
+			name: "redo with nothing applied",
+			run: func() ([]int64, error) {
+				_, err := migrator.Redo()
+				return nil, err
+			},
+			wantErr: ErrNothingToRollback,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.run()
+
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("versions = %v, want %v", got, test.want)
+			}
+
+			for table, want := range test.wantTables {
+				if exists := tableExists(t, db, table); exists != want {
+					t.Errorf("table %s exists = %v, want %v", table, exists, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMigratorFailedMigrationLeavesNothing(t *testing.T) {
+	db := testSchemaDB(t)
+
+	broken := append(append([]Migration{}, testMigrations[:1]...), Migration{
+		Version: 2,
+		Name:    "broken",
+		Up:      "CREATE TABLE half (id int); CREATE TABLE broken (id nonexistent_type)",
+		Down:    "DROP TABLE half",
+	})
+
+	applied, err := NewMigrator(db, broken).Up(0)
+	if err == nil || !strings.Contains(err.Error(), "migration 2_broken failed") {
+		t.Fatalf("error = %v, want the broken migration to fail", err)
+	}
+
+	if fmt.Sprint(versionsOf(applied)) != "[1]" {
+		t.Errorf("applied = %v, want [1]", versionsOf(applied))
+	}
+
+	if tableExists(t, db, "half") {
+		t.Error("statements of the failed migration were kept")
+	}
+
+	statuses, err := NewMigrator(db, broken).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statuses[1].AppliedAt != nil {
+		t.Error("failed migration was recorded as applied")
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	db := testSchemaDB(t)
+
+	if _, err := NewMigrator(db, testMigrations[:2]).Up(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// version 2 was applied by a build that had it, this one does not
+	statuses, err := NewMigrator(db, []Migration{testMigrations[0], testMigrations[2]}).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		version     int64
+		wantApplied bool
+		wantMissing bool
+	}{
+		{1, true, false},
+		{3, false, false},
+		{2, true, true},
+	}
+
+	if len(statuses) != len(tests) {
+		t.Fatalf("statuses = %+v, want %d", statuses, len(tests))
+	}
+
+	for i, test := range tests {
+		status := statuses[i]
+
+		if status.Version != test.version || (status.AppliedAt != nil) != test.wantApplied || status.Missing != test.wantMissing {
+			t.Errorf("status %d = %+v, want version %d applied %v missing %v", i, status, test.version, test.wantApplied, test.wantMissing)
+		}
+	}
+
+	if _, err := NewMigrator(db, []Migration{testMigrations[0], testMigrations[2]}).Down(2); err == nil || !strings.Contains(err.Error(), "has no file to roll back with") {
+		t.Errorf("rolling back a missing migration: error = %v", err)
+	}
+}
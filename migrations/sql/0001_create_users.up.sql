@@ -0,0 +1,17 @@
+-- IF NOT EXISTS lets databases created by the old AutoMigrate adopt the
+-- migrations without losing data.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username varchar(30) NOT NULL,
+    email varchar(50) NOT NULL,
+    phone_number varchar(20) NOT NULL,
+    password varchar NOT NULL,
+    role int DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_users_email;
+DROP INDEX IF EXISTS idx_users_username;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS suspended_at,
+    DROP COLUMN IF EXISTS totp_last_step,
+    DROP COLUMN IF EXISTS totp_enabled_at,
+    DROP COLUMN IF EXISTS totp_secret,
+    DROP COLUMN IF EXISTS verification_sent_at,
+    DROP COLUMN IF EXISTS pending_email,
+    DROP COLUMN IF EXISTS email_verified_at;
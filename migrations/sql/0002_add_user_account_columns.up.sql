@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_verified_at timestamptz,
+    ADD COLUMN IF NOT EXISTS pending_email varchar(50),
+    ADD COLUMN IF NOT EXISTS verification_sent_at timestamptz,
+    ADD COLUMN IF NOT EXISTS totp_secret varchar(64),
+    ADD COLUMN IF NOT EXISTS totp_enabled_at timestamptz,
+    ADD COLUMN IF NOT EXISTS totp_last_step bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS suspended_at timestamptz;
+
+-- emails are stored lowercase so the unique index is case-insensitive
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id smallserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name varchar(50) NOT NULL,
+    created_by_id uuid,
+    CONSTRAINT fk_categories_created_by FOREIGN KEY (created_by_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_categories_created_by_id ON categories (created_by_id);
+
+CREATE TABLE IF NOT EXISTS products (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name varchar(100) NOT NULL,
+    price decimal(10,2) NOT NULL,
+    stock int DEFAULT 0,
+    category_id bigint NOT NULL,
+    created_by_id uuid,
+    CONSTRAINT fk_categories_products FOREIGN KEY (category_id) REFERENCES categories (id),
+    CONSTRAINT fk_products_created_by FOREIGN KEY (created_by_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products (category_id);
+CREATE INDEX IF NOT EXISTS idx_products_created_by_id ON products (created_by_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    actor_id uuid,
+    action varchar(100) NOT NULL,
+    resource_type varchar(50) NOT NULL,
+    resource_id varchar(64),
+    changes jsonb,
+    ip_address varchar(45),
+    user_agent varchar(255),
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_resource ON audit_events (resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mfa_challenges;
+
+-- fails while sealed secrets are stored, rather than cutting them off
+ALTER TABLE users ALTER COLUMN totp_secret TYPE varchar(64);
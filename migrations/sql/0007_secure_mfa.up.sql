@@ -0,0 +1,16 @@
+-- sealed TOTP secrets are longer than the plain base32 ones
+ALTER TABLE users ALTER COLUMN totp_secret TYPE varchar(255);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    CONSTRAINT fk_mfa_challenges_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_deleted_at ON mfa_challenges (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_user_id ON mfa_challenges (user_id);
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ApiKey struct {
+	gorm.Model
+	ID         string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID     string     `json:"user_id" gorm:"type:uuid;index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);uniqueIndex;not null"`
+	SecretHash string     `json:"-" gorm:"type:varchar(64);not null"`
+	Scopes     string     `json:"scopes" gorm:"type:text;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// ScopeList returns the permissions the key is limited to. Scopes are stored
+// space separated, like an OAuth scope parameter.
+func (k *ApiKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditEvent struct {
+	ID           string          `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	ActorID      *string         `json:"actor_id" gorm:"type:uuid;index"`
+	Action       string          `json:"action" gorm:"type:varchar(100);index;not null"`
+	ResourceType string          `json:"resource_type" gorm:"type:varchar(50);index:idx_audit_events_resource;not null"`
+	ResourceID   string          `json:"resource_id" gorm:"type:varchar(64);index:idx_audit_events_resource"`
+	Changes      json.RawMessage `json:"changes" gorm:"type:jsonb"`
+	IPAddress    string          `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent    string          `json:"user_agent" gorm:"type:varchar(255)"`
+	CreatedAt    time.Time       `json:"created_at" gorm:"index"`
+}
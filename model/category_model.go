@@ -6,7 +6,9 @@ import (
 
 type Category struct {
 	gorm.Model
-	ID       uint16    `gorm:"primaryKey;autoIncrement"`
-	Name     string    `json:"name" gorm:"type:varchar(50);not null"`
-	Products []Product `gorm:"foreignKey:CategoryID"`
+	ID          uint16    `gorm:"primaryKey;autoIncrement"`
+	Name        string    `json:"name" gorm:"type:varchar(50);not null"`
+	Products    []Product `gorm:"foreignKey:CategoryID"`
+	CreatedByID *string   `json:"created_by_id" gorm:"type:uuid;index"`
+	CreatedBy   *User     `json:"-" gorm:"foreignKey:CreatedByID"`
 }
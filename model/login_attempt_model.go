@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"type:varchar(255);primaryKey"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"not null"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+type LoginEvent struct {
+	ID        string    `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    *string   `json:"user_id" gorm:"type:uuid;index"`
+	Email     string    `json:"email" gorm:"type:varchar(50);index;not null"`
+	IPAddress string    `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent string    `json:"user_agent" gorm:"type:varchar(255)"`
+	Outcome   string    `json:"outcome" gorm:"type:varchar(20);index;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
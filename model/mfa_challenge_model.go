@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MFAChallenge is a login waiting for its second factor. The challenge token
+// carries its ID, and it is marked used when the login completes.
+type MFAChallenge struct {
+	gorm.Model
+	ID        string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:uuid;index;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+}
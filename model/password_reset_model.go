@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetToken struct {
+	gorm.Model
+	ID        string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:uuid;index;not null"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryCode struct {
+	gorm.Model
+	ID       string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID   string     `json:"user_id" gorm:"type:uuid;index;not null"`
+	CodeHash string     `json:"-" gorm:"type:varchar(64);not null"`
+	UsedAt   *time.Time `json:"used_at"`
+	User     User       `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+type RevokedToken struct {
+	TokenID   string    `json:"token_id" gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null"`
+}
+
+type UserRevocation struct {
+	UserID    string    `json:"user_id" gorm:"type:uuid;primaryKey"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"not null"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+type Role struct {
+	gorm.Model
+	ID          uint         `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string       `json:"name" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Description string       `json:"description" gorm:"type:varchar(255)"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+}
+
+type Permission struct {
+	gorm.Model
+	ID          uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string `json:"name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	ID        string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string     `json:"user_id" gorm:"type:uuid;index;not null"`
+	FamilyID  string     `json:"family_id" gorm:"type:uuid;index;not null"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserAgent string     `json:"user_agent" gorm:"type:varchar(255)"`
+	IPAddress string     `json:"ip_address" gorm:"type:varchar(45)"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+}
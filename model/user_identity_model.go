@@ -0,0 +1,17 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider, identified by the provider's subject.
+type UserIdentity struct {
+	gorm.Model
+	ID       string `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID   string `json:"user_id" gorm:"type:uuid;index;not null"`
+	Provider string `json:"provider" gorm:"type:varchar(50);uniqueIndex:idx_user_identities_provider_subject;not null"`
+	Subject  string `json:"subject" gorm:"type:varchar(255);uniqueIndex:idx_user_identities_provider_subject;not null"`
+	Email    string `json:"email" gorm:"type:varchar(50)"`
+	User     User   `json:"-" gorm:"foreignKey:UserID"`
+}
@@ -1,15 +1,31 @@
 package model
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// legacy numeric roles checked by Authorize, kept in sync with the "admin" role
+const (
+	RoleUser  = 0
+	RoleAdmin = 1
+)
+
 type User struct {
 	gorm.Model
-	ID          string `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
-	Username    string `json:"username" gorm:"type:varchar(30);not null"`
-	Email       string `json:"email" gorm:"type:varchar(50);not null"`
-	PhoneNumber string `json:"phone_number" gorm:"type:varchar(20);not null"`
-	Password    string `json:"password" gorm:"type:varchar;not null"`
-	Role        int    `json:"role" gorm:"type:int;default:0"`
-}
\ No newline at end of file
+	ID                 string     `json:"id" gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Username           string     `json:"username" gorm:"type:varchar(30);uniqueIndex;not null"`
+	Email              string     `json:"email" gorm:"type:varchar(50);uniqueIndex;not null"`
+	PhoneNumber        string     `json:"phone_number" gorm:"type:varchar(20);not null"`
+	Password           string     `json:"-" gorm:"type:varchar;not null"`
+	Role               int        `json:"role" gorm:"type:int;default:0"`
+	Roles              []Role     `json:"roles" gorm:"many2many:user_roles"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	PendingEmail       *string    `json:"-" gorm:"type:varchar(50)"`
+	VerificationSentAt *time.Time `json:"-"`
+	TOTPSecret         string     `json:"-" gorm:"type:varchar(255)"`
+	TOTPEnabledAt      *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep       int64      `json:"-" gorm:"default:0"`
+	SuspendedAt        *time.Time `json:"suspended_at"`
+}
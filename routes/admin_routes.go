@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminRoutes interface {
+	AdminGroup()
+}
+
+type implAdminRoutes struct {
+	router     fiber.Router
+	service    service.AdminService
+	middleware middleware.Middleware
+}
+
+func NewAdminRoutes(router fiber.Router, service service.AdminService, middleware middleware.Middleware) AdminRoutes {
+	return &implAdminRoutes{
+		router:     router,
+		service:    service,
+		middleware: middleware,
+	}
+}
+
+func (r *implAdminRoutes) AdminGroup() {
+	AdminGroup := r.router.Group("/admin")
+
+	AdminGroup.Get("/roles", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("role:manage"), r.service.GetAllRoles)
+	AdminGroup.Get("/users/:id/roles", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("role:manage"), r.service.GetUserRoles)
+	AdminGroup.Post("/users/:id/roles", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("role:manage"), r.service.AssignRole)
+	AdminGroup.Delete("/users/:id/roles/:role", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("role:manage"), r.service.RemoveRole)
+	AdminGroup.Post("/users/:id/unlock", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.UnlockUser)
+
+	AdminGroup.Get("/users", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.GetAllUsers)
+	AdminGroup.Get("/users/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.GetUserById)
+	AdminGroup.Put("/users/:id/roles", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("role:manage"), r.service.SetUserRoles)
+	AdminGroup.Post("/users/:id/suspend", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.SuspendUser)
+	AdminGroup.Post("/users/:id/unsuspend", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.UnsuspendUser)
+	AdminGroup.Delete("/users/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.DeleteUser)
+	AdminGroup.Post("/users/:id/restore", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("user:manage"), r.service.RestoreUser)
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ApiKeyRoutes interface {
+	ApiKeyGroup()
+}
+
+type implApiKeyRoutes struct {
+	router     fiber.Router
+	service    service.ApiKeyService
+	middleware middleware.Middleware
+}
+
+func NewApiKeyRoutes(router fiber.Router, service service.ApiKeyService, middleware middleware.Middleware) ApiKeyRoutes {
+	return &implApiKeyRoutes{
+		router:     router,
+		service:    service,
+		middleware: middleware,
+	}
+}
+
+func (r *implApiKeyRoutes) ApiKeyGroup() {
+	ApiKeyGroup := r.router.Group("/user/api-keys", r.middleware.Authenticate)
+
+	ApiKeyGroup.Get("/", r.service.GetApiKeys)
+	ApiKeyGroup.Post("/", r.service.CreateApiKey)
+	ApiKeyGroup.Delete("/:id", r.service.RevokeApiKey)
+}
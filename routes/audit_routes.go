@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"app/middleware"
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditRoutes interface {
+	AuditGroup()
+}
+
+type implAuditRoutes struct {
+	router     fiber.Router
+	service    service.AuditService
+	middleware middleware.Middleware
+}
+
+func NewAuditRoutes(router fiber.Router, service service.AuditService, middleware middleware.Middleware) AuditRoutes {
+	return &implAuditRoutes{
+		router:     router,
+		service:    service,
+		middleware: middleware,
+	}
+}
+
+func (r *implAuditRoutes) AuditGroup() {
+	AuditGroup := r.router.Group("/admin/audit-events")
+
+	AuditGroup.Get("/", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("audit:read"), r.service.GetAuditEvents)
+	AuditGroup.Get("/export", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequirePermission("audit:read"), r.service.ExportAuditEvents)
+}
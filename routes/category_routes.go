@@ -2,6 +2,7 @@ package routes
 
 import (
 	"app/middleware"
+	"app/model"
 	"app/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,9 +29,9 @@ func NewCategoryRoutes(router fiber.Router, service service.CategoryService, mid
 func (r *implCategoryRoutes) CategoryGroup() {
 	categoryRoutes := r.router.Group("/category")
 
-	categoryRoutes.Post("/", r.middleware.Authenticate, r.middleware.GetCredential, r.service.CreateCategory)
+	categoryRoutes.Post("/", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.GetCredential, r.middleware.RequirePermission("category:create"), r.service.CreateCategory)
 	categoryRoutes.Get("/", r.service.GetAllCategory)
 	categoryRoutes.Get("/:id", r.service.GetCategoryById)
-	categoryRoutes.Put("/:id", r.middleware.Authenticate, r.middleware.GetCredential, r.service.UpdateCategory)
-	categoryRoutes.Delete("/:id", r.middleware.Authenticate, r.middleware.GetCredential, r.service.DeleteCategory)
+	categoryRoutes.Put("/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequireOwnership(&model.Category{}, "category:update:own", "category:update"), r.service.UpdateCategory)
+	categoryRoutes.Delete("/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequireOwnership(&model.Category{}, "category:delete:own", "category:delete"), r.service.DeleteCategory)
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"app/middleware"
+	"app/model"
 	"app/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -28,10 +29,10 @@ func NewProductRoutes(router fiber.Router, service service.ProductService, middl
 func (r *implProductRoutes) ProductGroup() {
 	ProductGroup := r.router.Group("/product")
 
-	ProductGroup.Post("/", r.middleware.Authenticate, r.middleware.GetCredential, r.middleware.Authorize(0, 1), r.service.CreateProduct)
+	ProductGroup.Post("/", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.GetCredential, r.middleware.RequirePermission("product:create"), r.service.CreateProduct)
 	ProductGroup.Get("/", r.service.GetAllProducts)
 	ProductGroup.Get("/page", r.service.PaginatedProduct)
 	ProductGroup.Get("/:id", r.service.GetProductById)
-	ProductGroup.Put("/:id", r.middleware.Authenticate, r.middleware.GetCredential, r.service.UpdateProduct)
-	ProductGroup.Delete("/:id", r.middleware.Authenticate, r.middleware.GetCredential, r.service.DeleteProduct)
+	ProductGroup.Put("/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequireOwnership(&model.Product{}, "product:update:own", "product:update"), r.service.UpdateProduct)
+	ProductGroup.Delete("/:id", r.middleware.AllowAPIKey, r.middleware.Authenticate, r.middleware.RequireOwnership(&model.Product{}, "product:delete:own", "product:delete"), r.service.DeleteProduct)
 }
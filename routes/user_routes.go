@@ -30,5 +30,20 @@ func (r *implUserRoutes) UserGroup() {
 
 	UserGroup.Post("/register", r.service.Register)
 	UserGroup.Post("/Login", r.service.Login)
+	UserGroup.Post("/login/verify", r.service.VerifyLogin)
+	UserGroup.Post("/refresh", r.service.Refresh)
+	UserGroup.Post("/logout", r.middleware.Authenticate, r.service.Logout)
+	UserGroup.Post("/logout-all", r.middleware.Authenticate, r.service.LogoutAll)
 	UserGroup.Put("/update-password", r.middleware.Authenticate, r.service.UpdatePassword)
+	UserGroup.Post("/forgot-password", r.service.ForgotPassword)
+	UserGroup.Post("/reset-password", r.service.ResetPassword)
+	UserGroup.Get("/verify-email", r.service.VerifyEmail)
+	UserGroup.Post("/resend-verification", r.service.ResendVerification)
+	UserGroup.Post("/2fa/enroll", r.middleware.Authenticate, r.service.EnrollTOTP)
+	UserGroup.Post("/2fa/confirm", r.middleware.Authenticate, r.service.ConfirmTOTP)
+	UserGroup.Post("/2fa/disable", r.middleware.Authenticate, r.service.DisableTOTP)
+	UserGroup.Get("/me", r.middleware.Authenticate, r.middleware.GetCredential, r.service.GetMe)
+	UserGroup.Patch("/me", r.middleware.Authenticate, r.middleware.GetCredential, r.service.UpdateMe)
+	UserGroup.Get("/oauth/:provider", r.service.OIDCLogin)
+	UserGroup.Get("/oauth/:provider/callback", r.service.OIDCCallback)
 }
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WellKnownRoutes interface {
+	WellKnownGroup()
+}
+
+type implWellKnownRoutes struct {
+	router  fiber.Router
+	service service.KeyService
+}
+
+func NewWellKnownRoutes(router fiber.Router, service service.KeyService) WellKnownRoutes {
+	return &implWellKnownRoutes{
+		router:  router,
+		service: service,
+	}
+}
+
+func (r *implWellKnownRoutes) WellKnownGroup() {
+	wellKnownRoutes := r.router.Group("/.well-known")
+
+	wellKnownRoutes.Get("/jwks.json", r.service.JWKS)
+}
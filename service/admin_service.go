@@ -0,0 +1,224 @@
+package service
+
+import (
+	"time"
+
+	"app/audit"
+	"app/auth"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type AdminService interface {
+	GetAllRoles(c *fiber.Ctx) error
+	GetUserRoles(c *fiber.Ctx) error
+	AssignRole(c *fiber.Ctx) error
+	RemoveRole(c *fiber.Ctx) error
+	UnlockUser(c *fiber.Ctx) error
+	GetAllUsers(c *fiber.Ctx) error
+	GetUserById(c *fiber.Ctx) error
+	SetUserRoles(c *fiber.Ctx) error
+	SuspendUser(c *fiber.Ctx) error
+	UnsuspendUser(c *fiber.Ctx) error
+	DeleteUser(c *fiber.Ctx) error
+	RestoreUser(c *fiber.Ctx) error
+}
+
+type implAdminService struct {
+	db          *gorm.DB
+	sessions    auth.SessionManager
+	revocations auth.RevocationStore
+	throttle    auth.LoginThrottle
+	audit       audit.Recorder
+}
+
+func NewAdminService(db *gorm.DB, sessions auth.SessionManager, revocations auth.RevocationStore, throttle auth.LoginThrottle, audit audit.Recorder) AdminService {
+	return &implAdminService{
+		db:          db,
+		sessions:    sessions,
+		revocations: revocations,
+		throttle:    throttle,
+		audit:       audit,
+	}
+}
+
+func (s *implAdminService) GetAllRoles(c *fiber.Ctx) error {
+	roles := make([]model.Role, 0)
+
+	if err := s.db.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "unable to get all roles",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(roles)
+}
+
+func (s *implAdminService) GetUserRoles(c *fiber.Ctx) error {
+	user := &model.User{}
+
+	id := c.Params("id")
+
+	if err := s.db.Select("id").Preload("Roles").Where("id = ?", id).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "User not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(user.Roles)
+}
+
+type RoleAssignmentStruct struct {
+	Role string `json:"role" validate:"required,max=50"`
+}
+
+func (s *implAdminService) AssignRole(c *fiber.Ctx) error {
+	body := new(RoleAssignmentStruct)
+
+	id := c.Params("id")
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	user, role, err := s.findUserAndRole(id, body.Role)
+	if err != nil {
+		return s.roleLookupError(c, err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Association("Roles").Append(role); err != nil {
+			return err
+		}
+		return syncLegacyRole(tx, user.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to assign role",
+		})
+	}
+
+	s.recordUserEvent(c, "user.role_assign", user.ID, nil, fiber.Map{"role": role.Name})
+
+	return s.expireAccessTokens(c, user.ID, "role assigned")
+}
+
+func (s *implAdminService) RemoveRole(c *fiber.Ctx) error {
+	user, role, err := s.findUserAndRole(c.Params("id"), c.Params("role"))
+	if err != nil {
+		return s.roleLookupError(c, err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Association("Roles").Delete(role); err != nil {
+			return err
+		}
+		return syncLegacyRole(tx, user.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to remove role",
+		})
+	}
+
+	s.recordUserEvent(c, "user.role_remove", user.ID, fiber.Map{"role": role.Name}, nil)
+
+	return s.expireAccessTokens(c, user.ID, "role removed")
+}
+
+func (s *implAdminService) findUserAndRole(userID, roleName string) (*model.User, *model.Role, error) {
+	user := &model.User{}
+	if err := s.db.Select("id").Where("id = ?", userID).First(user).Error; err != nil {
+		return nil, nil, err
+	}
+
+	role := &model.Role{}
+	if err := s.db.Where("name = ?", roleName).First(role).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return user, role, nil
+}
+
+func (s *implAdminService) roleLookupError(c *fiber.Ctx, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User or role not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": "database error",
+	})
+}
+
+// expireAccessTokens invalidates the user's current access tokens but keeps
+// their refresh tokens, so the next refresh picks up the new roles.
+func (s *implAdminService) expireAccessTokens(c *fiber.Ctx, userID, message string) error {
+	if err := s.revocations.RevokeUser(userID, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to expire existing tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+func (s *implAdminService) UnlockUser(c *fiber.Ctx) error {
+	user := &model.User{}
+
+	id := c.Params("id")
+
+	if err := s.db.Select("id", "email").Where("id = ?", id).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "User not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if err := s.throttle.Unlock(user.Email); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to unlock user",
+		})
+	}
+
+	s.recordUserEvent(c, "user.unlock", user.ID, nil, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user unlocked",
+	})
+}
+
+// recordUserEvent audits an action an admin takes on another user's account.
+func (s *implAdminService) recordUserEvent(c *fiber.Ctx, action, userID string, before, after interface{}) {
+	s.audit.Record(c, audit.Event{
+		Action:       action,
+		ResourceType: "user",
+		ResourceID:   userID,
+		Before:       before,
+		After:        after,
+	})
+}
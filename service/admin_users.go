@@ -0,0 +1,349 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errUnknownRole = errors.New("unknown role")
+
+// AdminUserResponse adds the account state only admins get to see.
+type AdminUserResponse struct {
+	UserResponse
+	SuspendedAt *time.Time `json:"suspended_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func newAdminUserResponse(user *model.User, roles []string) AdminUserResponse {
+	response := AdminUserResponse{
+		UserResponse: NewUserResponse(user, roles),
+		SuspendedAt:  user.SuspendedAt,
+	}
+
+	if user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+
+	return response
+}
+
+// GetAllUsers lists users page by page. It filters on ?role=, ?verified=,
+// ?suspended=, ?created_from= and ?created_to= (RFC 3339), searches username
+// and email with ?search=, and includes soft deleted users with ?deleted=true.
+func (s *implAdminService) GetAllUsers(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "0"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	sortOrder := "desc"
+	if c.Query("sort") == "asc" {
+		sortOrder = "asc"
+	}
+
+	query := s.db.Model(&model.User{})
+
+	if c.QueryBool("deleted") {
+		query = query.Unscoped()
+	}
+
+	if search := c.Query("search"); search != "" {
+		query = query.Where("(username ILIKE ? OR email ILIKE ?)", "%"+search+"%", "%"+search+"%")
+	}
+
+	if role := c.Query("role"); role != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE user_roles.user_id = users.id AND roles.name = ?)", role)
+	}
+
+	if verified := c.Query("verified"); verified != "" {
+		if c.QueryBool("verified") {
+			query = query.Where("email_verified_at IS NOT NULL")
+		} else {
+			query = query.Where("email_verified_at IS NULL")
+		}
+	}
+
+	if suspended := c.Query("suspended"); suspended != "" {
+		if c.QueryBool("suspended") {
+			query = query.Where("suspended_at IS NOT NULL")
+		} else {
+			query = query.Where("suspended_at IS NULL")
+		}
+	}
+
+	for param, condition := range map[string]string{"created_from": "created_at >= ?", "created_to": "created_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": param + " must be an RFC 3339 timestamp",
+			})
+		}
+
+		query = query.Where(condition, at)
+	}
+
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to count users",
+		})
+	}
+
+	users := make([]model.User, 0)
+
+	if err := query.Preload("Roles").Offset(limit * page).Limit(limit).Order("created_at " + sortOrder).Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to retrieve users",
+		})
+	}
+
+	data := make([]AdminUserResponse, 0, len(users))
+	for i := range users {
+		data = append(data, newAdminUserResponse(&users[i], roleNames(users[i].Roles)))
+	}
+
+	totalPages := int(totalRows) / limit
+	if int(totalRows)%limit != 0 {
+		totalPages++
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":         data,
+		"current_page": page,
+		"data_limit":   limit,
+		"total_rows":   totalRows,
+		"total_pages":  totalPages,
+	})
+}
+
+func (s *implAdminService) GetUserById(c *fiber.Ctx) error {
+	user := &model.User{}
+
+	if err := s.db.Unscoped().Preload("Roles").Where("id = ?", c.Params("id")).First(user).Error; err != nil {
+		return userLookupError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newAdminUserResponse(user, roleNames(user.Roles)))
+}
+
+type SetRolesStruct struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,required,max=50"`
+}
+
+// SetUserRoles replaces every role of the user with the given ones.
+func (s *implAdminService) SetUserRoles(c *fiber.Ctx) error {
+	body := new(SetRolesStruct)
+
+	id := c.Params("id")
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	var previous []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		user := &model.User{}
+		if err := tx.Select("id").Preload("Roles").Where("id = ?", id).First(user).Error; err != nil {
+			return err
+		}
+
+		previous = roleNames(user.Roles)
+
+		roles := make([]model.Role, 0)
+		if err := tx.Where("name IN ?", body.Roles).Find(&roles).Error; err != nil {
+			return err
+		}
+
+		if len(roles) != len(uniqueStrings(body.Roles)) {
+			return errUnknownRole
+		}
+
+		if err := tx.Model(user).Association("Roles").Replace(roles); err != nil {
+			return err
+		}
+
+		return syncLegacyRole(tx, user.ID)
+	})
+
+	if err != nil {
+		if errors.Is(err, errUnknownRole) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "unknown role",
+			})
+		}
+		return userLookupError(c, err)
+	}
+
+	s.recordUserEvent(c, "user.roles_set", id, fiber.Map{"roles": previous}, fiber.Map{"roles": uniqueStrings(body.Roles)})
+
+	return s.expireAccessTokens(c, id, "roles updated")
+}
+
+func (s *implAdminService) SuspendUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if id == c.Locals("user_id") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "you cannot suspend your own account",
+		})
+	}
+
+	result := s.db.Model(&model.User{}).Where("id = ? AND suspended_at IS NULL", id).Update("suspended_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found or already suspended",
+		})
+	}
+
+	s.recordUserEvent(c, "user.suspend", id, nil, nil)
+
+	return s.signOutEverywhere(c, id, "user suspended")
+}
+
+func (s *implAdminService) UnsuspendUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	result := s.db.Model(&model.User{}).Where("id = ? AND suspended_at IS NOT NULL", id).Update("suspended_at", nil)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found or not suspended",
+		})
+	}
+
+	s.recordUserEvent(c, "user.unsuspend", id, nil, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user unsuspended",
+	})
+}
+
+func (s *implAdminService) DeleteUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if id == c.Locals("user_id") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "you cannot delete your own account",
+		})
+	}
+
+	result := s.db.Where("id = ?", id).Delete(&model.User{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	s.recordUserEvent(c, "user.delete", id, nil, nil)
+
+	return s.signOutEverywhere(c, id, "user deleted")
+}
+
+func (s *implAdminService) RestoreUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	result := s.db.Unscoped().Model(&model.User{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return databaseError(c, result.Error, "database error")
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Deleted user not found",
+		})
+	}
+
+	s.recordUserEvent(c, "user.restore", id, nil, nil)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "user restored",
+	})
+}
+
+// signOutEverywhere ends every session of the user and kills the access tokens
+// already handed out.
+func (s *implAdminService) signOutEverywhere(c *fiber.Ctx, userID, message string) error {
+	if err := s.sessions.RevokeAll(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to revoke sessions",
+		})
+	}
+
+	return s.expireAccessTokens(c, userID, message)
+}
+
+// syncLegacyRole keeps the numeric role Authorize checks in line with the
+// user's membership of the "admin" role.
+func syncLegacyRole(tx *gorm.DB, userID string) error {
+	return tx.Exec(`
+		UPDATE users SET role = CASE WHEN EXISTS (
+			SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = users.id AND roles.name = 'admin' AND roles.deleted_at IS NULL
+		) THEN ? ELSE ? END
+		WHERE id = ?
+	`, model.RoleAdmin, model.RoleUser, userID).Error
+}
+
+func userLookupError(c *fiber.Ctx, err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "User not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": "database error",
+	})
+}
+
+func roleNames(roles []model.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
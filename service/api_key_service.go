@@ -0,0 +1,186 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"app/audit"
+	"app/auth"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ApiKeyService interface {
+	CreateApiKey(c *fiber.Ctx) error
+	GetApiKeys(c *fiber.Ctx) error
+	RevokeApiKey(c *fiber.Ctx) error
+}
+
+type implApiKeyService struct {
+	db    *gorm.DB
+	audit audit.Recorder
+}
+
+func NewApiKeyService(db *gorm.DB, audit audit.Recorder) ApiKeyService {
+	return &implApiKeyService{
+		db:    db,
+		audit: audit,
+	}
+}
+
+type ApiKeyStruct struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s *implApiKeyService) CreateApiKey(c *fiber.Ctx) error {
+	body := new(ApiKeyStruct)
+
+	userID, _ := c.Locals("user_id").(string)
+
+	// a leaked key must not be able to mint more keys
+	if c.Locals("auth_source") == "api_key" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "api keys cannot manage api keys",
+		})
+	}
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "expires_at must be in the future",
+		})
+	}
+
+	var known int64
+
+	if err := s.db.Model(&model.Permission{}).Where("name IN ?", body.Scopes).Count(&known).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if int(known) != len(uniqueStrings(body.Scopes)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "unknown scope",
+		})
+	}
+
+	prefix, key, secretHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to generate api key",
+		})
+	}
+
+	apiKey := &model.ApiKey{
+		UserID:     userID,
+		Name:       body.Name,
+		Prefix:     prefix,
+		SecretHash: secretHash,
+		Scopes:     strings.Join(uniqueStrings(body.Scopes), " "),
+		ExpiresAt:  body.ExpiresAt,
+	}
+
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "unable to create api key",
+		})
+	}
+
+	s.audit.Record(c, audit.Event{
+		Action:       "api_key.create",
+		ResourceType: "api_key",
+		ResourceID:   apiKey.ID,
+		After:        apiKey,
+	})
+
+	// the plain key is only ever shown here
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "api key created",
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+func (s *implApiKeyService) GetApiKeys(c *fiber.Ctx) error {
+	apiKeys := make([]model.ApiKey, 0)
+
+	userID, _ := c.Locals("user_id").(string)
+
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "unable to get api keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(apiKeys)
+}
+
+func (s *implApiKeyService) RevokeApiKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	userID, _ := c.Locals("user_id").(string)
+
+	if c.Locals("auth_source") == "api_key" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "api keys cannot manage api keys",
+		})
+	}
+
+	result := s.db.Model(&model.ApiKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Api key not found",
+		})
+	}
+
+	s.audit.Record(c, audit.Event{
+		Action:       "api_key.revoke",
+		ResourceType: "api_key",
+		ResourceID:   id,
+	})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "api key revoked",
+	})
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
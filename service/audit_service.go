@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const auditExportLimit = 50000
+
+type AuditService interface {
+	GetAuditEvents(c *fiber.Ctx) error
+	ExportAuditEvents(c *fiber.Ctx) error
+}
+
+type implAuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) AuditService {
+	return &implAuditService{
+		db: db,
+	}
+}
+
+func (s *implAuditService) GetAuditEvents(c *fiber.Ctx) error {
+	page, err := strconv.Atoi(c.Query("page", "0"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	query, err := s.filter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to count audit events",
+		})
+	}
+
+	events := make([]model.AuditEvent, 0)
+
+	if err := query.Offset(limit * page).Limit(limit).Order("created_at DESC").Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to retrieve audit events",
+		})
+	}
+
+	totalPages := int(totalRows) / limit
+	if int(totalRows)%limit != 0 {
+		totalPages++
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"data":         events,
+		"current_page": page,
+		"data_limit":   limit,
+		"total_rows":   totalRows,
+		"total_pages":  totalPages,
+	})
+}
+
+// ExportAuditEvents returns the filtered events as CSV, newest first and capped
+// at auditExportLimit rows.
+func (s *implAuditService) ExportAuditEvents(c *fiber.Ctx) error {
+	query, err := s.filter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	rows, err := query.Order("created_at DESC").Limit(auditExportLimit).Rows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to retrieve audit events",
+		})
+	}
+	defer rows.Close()
+
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+
+	writer.Write([]string{"id", "created_at", "actor_id", "action", "resource_type", "resource_id", "changes", "ip_address", "user_agent"})
+
+	for rows.Next() {
+		event := model.AuditEvent{}
+		if err := s.db.ScanRows(rows, &event); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Failed to retrieve audit events",
+			})
+		}
+
+		actorID := ""
+		if event.ActorID != nil {
+			actorID = *event.ActorID
+		}
+
+		writer.Write([]string{
+			event.ID,
+			event.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(actorID),
+			csvCell(event.Action),
+			csvCell(event.ResourceType),
+			csvCell(event.ResourceID),
+			csvCell(string(event.Changes)),
+			csvCell(event.IPAddress),
+			csvCell(event.UserAgent),
+		})
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to export audit events",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-events.csv"`)
+
+	return c.Send(buf.Bytes())
+}
+
+// csvCell keeps a spreadsheet from running a value as a formula. Clients pick
+// their user agent and much of what ends up in resource ids and changes.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// filter applies ?actor_id=, ?action=, ?resource_type=, ?resource_id= and the
+// ?from= / ?to= RFC 3339 time range.
+func (s *implAuditService) filter(c *fiber.Ctx) (*gorm.DB, error) {
+	query := s.db.Model(&model.AuditEvent{})
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		if _, err := uuid.Parse(actorID); err != nil {
+			return nil, errors.New("actor_id must be a uuid")
+		}
+	}
+
+	for param, column := range map[string]string{
+		"actor_id":      "actor_id",
+		"action":        "action",
+		"resource_type": "resource_type",
+		"resource_id":   "resource_id",
+	} {
+		if value := c.Query(param); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New(param + " must be an RFC 3339 timestamp")
+		}
+
+		query = query.Where(condition, at)
+	}
+
+	return query, nil
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"fmt"
+
+	"app/audit"
 	"app/model"
 
 	"github.com/go-playground/validator/v10"
@@ -17,12 +20,16 @@ type CategoryService interface {
 }
 
 type implCategoryService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	reads *gorm.DB
+	audit audit.Recorder
 }
 
-func NewCategoryService(db *gorm.DB) CategoryService {
+func NewCategoryService(db *gorm.DB, reads *gorm.DB, audit audit.Recorder) CategoryService {
 	return &implCategoryService{
-		db: db,
+		db:    db,
+		reads: reads,
+		audit: audit,
 	}
 }
 
@@ -47,16 +54,29 @@ func (s *implCategoryService) CreateCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	user, ok := c.Locals("user").(*model.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid session",
+		})
+	}
+
 	category := &model.Category{
-		Name: body.Name,
+		Name:        body.Name,
+		CreatedByID: &user.ID,
 	}
 
 	if err := s.db.Create(category).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "failed to create category",
-		})
+		return databaseError(c, err, "failed to create category")
 	}
 
+	s.audit.Record(c, audit.Event{
+		Action:       "category.create",
+		ResourceType: "category",
+		ResourceID:   fmt.Sprint(category.ID),
+		After:        category,
+	})
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "new category created",
 	})
@@ -65,7 +85,7 @@ func (s *implCategoryService) CreateCategory(c *fiber.Ctx) error {
 func (s *implCategoryService) GetAllCategory(c *fiber.Ctx) error {
 	category := make([]model.Category, 0)
 
-	if err := s.db.Preload("Products").Find(&category).Error; err != nil {
+	if err := s.reads.Preload("Products").Find(&category).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "unable to get all category",
 		})
@@ -79,7 +99,7 @@ func (s *implCategoryService) GetCategoryById(c *fiber.Ctx) error {
 
 	id := c.Params("id")
 
-	if err := s.db.First(category, id).Error; err != nil {
+	if err := s.reads.First(category, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"message": "Category not found",
@@ -112,6 +132,19 @@ func (s *implCategoryService) UpdateCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	before := &model.Category{}
+
+	if err := s.db.First(before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Category not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
 	category := &model.Category{
 		Name: body.Name,
 	}
@@ -122,8 +155,18 @@ func (s *implCategoryService) UpdateCategory(c *fiber.Ctx) error {
 				"message": "Category not found",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "database error",
+		return databaseError(c, err, "database error")
+	}
+
+	after := &model.Category{}
+
+	if err := s.db.First(after, id).Error; err == nil {
+		s.audit.Record(c, audit.Event{
+			Action:       "category.update",
+			ResourceType: "category",
+			ResourceID:   id,
+			Before:       before,
+			After:        after,
 		})
 	}
 
@@ -137,6 +180,17 @@ func (s *implCategoryService) DeleteCategory(c *fiber.Ctx) error {
 
 	id := c.Params("id")
 
+	if err := s.db.First(category, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Category not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
 	if err := s.db.Delete(category, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -148,6 +202,13 @@ func (s *implCategoryService) DeleteCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	s.audit.Record(c, audit.Event{
+		Action:       "category.delete",
+		ResourceType: "category",
+		ResourceID:   id,
+		Before:       category,
+	})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "category deleted",
 	})
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const pgUniqueViolation = "23505"
+
+// pgKeyDetail pulls the columns out of a detail like "Key (email)=(a@b.c) already exists."
+var pgKeyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// uniqueViolation reports whether err is a Postgres unique violation and, if so,
+// which field caused it.
+func uniqueViolation(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return "", false
+	}
+
+	if match := pgKeyDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+		return match[1], true
+	}
+
+	return pgErr.ConstraintName, true
+}
+
+// databaseError answers a failed write. Unique violations become a 409 naming
+// the conflicting field; anything else is a 500 with the given message, the
+// raw database error is never sent to the client.
+func databaseError(c *fiber.Ctx, err error, message string) error {
+	if field, ok := uniqueViolation(err); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": strings.ReplaceAll(field, "_", " ") + " already exists",
+			"field":   field,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
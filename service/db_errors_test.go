@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantField string
+		wantOK    bool
+	}{
+		{
+			name:      "single column",
+			err:       &pgconn.PgError{Code: "23505", Detail: "Key (email)=(jane@example.com) already exists.", ConstraintName: "idx_users_email"},
+			wantField: "email",
+			wantOK:    true,
+		},
+		{
+			name:      "wrapped",
+			err:       fmt.Errorf("create user: %w", &pgconn.PgError{Code: "23505", Detail: "Key (username)=(jane) already exists."}),
+			wantField: "username",
+			wantOK:    true,
+		},
+		{
+			name:      "several columns",
+			err:       &pgconn.PgError{Code: "23505", Detail: "Key (provider, subject)=(google, 123) already exists."},
+			wantField: "provider, subject",
+			wantOK:    true,
+		},
+		{
+			name:      "detail hidden by the server",
+			err:       &pgconn.PgError{Code: "23505", ConstraintName: "idx_categories_name"},
+			wantField: "idx_categories_name",
+			wantOK:    true,
+		},
+		{
+			name: "foreign key violation",
+			err:  &pgconn.PgError{Code: "23503", Detail: "Key (category_id)=(7) is not present in table \"categories\"."},
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection refused"),
+		},
+		{
+			name: "record not found",
+			err:  gorm.ErrRecordNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			field, ok := uniqueViolation(test.err)
+			if field != test.wantField || ok != test.wantOK {
+				t.Errorf("uniqueViolation = %q, %v, want %q, %v", field, ok, test.wantField, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestUniqueViolationFromPostgres(t *testing.T) {
+	db := testDB(t)
+
+	if err := db.Create(&model.User{Username: "jane", Email: "jane@example.com", Password: "x"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		user *model.User
+		want string
+	}{
+		{"same email", &model.User{Username: "other", Email: "jane@example.com", Password: "x"}, "email"},
+		{"same username", &model.User{Username: "jane", Email: "other@example.com", Password: "x"}, "username"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a failed statement aborts the transaction, so run it in a savepoint
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return tx.Create(test.user).Error
+			})
+
+			if field, ok := uniqueViolation(err); !ok || field != test.want {
+				t.Errorf("uniqueViolation(%v) = %q, %v, want %q", err, field, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "unique violation",
+			err:         &pgconn.PgError{Code: "23505", Detail: "Key (phone_number)=(123) already exists."},
+			wantStatus:  fiber.StatusConflict,
+			wantMessage: "phone number already exists",
+		},
+		{
+			name:        "anything else",
+			err:         &pgconn.PgError{Code: "42P01", Message: "relation \"users\" does not exist"},
+			wantStatus:  fiber.StatusInternalServerError,
+			wantMessage: "failed to create user",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return databaseError(c, test.err, "failed to create user")
+			})
+
+			res, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := map[string]string{}
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.wantStatus || body["message"] != test.wantMessage {
+				t.Errorf("response = %d %q, want %d %q", res.StatusCode, body["message"], test.wantStatus, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := map[string]string{
+		"jane@example.com":       "jane@example.com",
+		"  Jane.Doe@Example.COM": "jane.doe@example.com",
+		"":                       "",
+	}
+
+	for input, want := range tests {
+		if got := normalizeEmail(input); got != want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+
+	"app/migrations/dbtest"
+	"app/model"
+
+	"gorm.io/gorm"
+)
+
+// testDB returns a transaction on the migrated test database with the roles
+// registration relies on.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	tx := dbtest.New(t)
+
+	if err := tx.Where(model.Role{Name: "user"}).FirstOrCreate(&model.Role{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"app/auth"
+	"app/mailer"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+func (s *implUserService) sendVerificationEmail(user *model.User) error {
+	token, err := auth.IssuePurposeToken(s.keys, auth.PurposeEmailVerification, &auth.PurposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID},
+		Email:            user.Email,
+	}, s.config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.EmailVerificationURL, token)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s",
+			user.Username, s.config.EmailVerificationTTL, link,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Model(&model.User{}).Where("id = ?", user.ID).Update("verification_sent_at", time.Now()).Error
+}
+
+// VerifyEmail handles both the link sent on registration and the one sent to
+// confirm a new address.
+func (s *implUserService) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+
+	if claims, err := auth.ParsePurposeToken(s.keys, auth.PurposeEmailChange, token); err == nil {
+		return s.confirmEmailChange(c, claims)
+	}
+
+	claims, err := auth.ParsePurposeToken(s.keys, auth.PurposeEmailVerification, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenExpired) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "verification link has expired",
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid verification link",
+		})
+	}
+
+	// the email check stops an old link from verifying an address the user has since changed
+	result := s.db.Model(&model.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", claims.Subject, claims.Email).
+		Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid verification link",
+		})
+	}
+
+	s.recordUserEvent(c, "user.email_verify", claims.Subject, nil, fiber.Map{"email": claims.Email})
+
+	return c.JSON(fiber.Map{
+		"message": "email verified",
+	})
+}
+
+type ResendVerificationStruct struct {
+	Email string `json:"email" validate:"required,max=50"`
+}
+
+func (s *implUserService) ResendVerification(c *fiber.Ctx) error {
+	body := new(ResendVerificationStruct)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	// like forgot-password, the answer never reveals whether the account exists
+	response := fiber.Map{
+		"message": "if the email is registered and unverified, a new link has been sent",
+	}
+
+	body.Email = normalizeEmail(body.Email)
+
+	user := &model.User{}
+
+	if err := s.db.Where("email = ?", body.Email).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(response)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return c.JSON(response)
+	}
+
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < s.config.EmailVerificationResendInterval {
+		return c.JSON(response)
+	}
+
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("failed to send verification email: %v", err)
+	}
+
+	return c.JSON(response)
+}
+
+// tokenLink adds the token to the query of a configured link, keeping any
+// parameters the link already has.
+func tokenLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
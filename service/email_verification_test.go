@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+func TestTokenLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		token string
+		want  string
+	}{
+		{"plain link", "http://localhost:3000/verify", "abc", "http://localhost:3000/verify?token=abc"},
+		{"link with a query", "https://app.example.com/reset?lang=en", "abc", "https://app.example.com/reset?lang=en&token=abc"},
+		{"link with a token already", "https://app.example.com/reset?token=old", "new", "https://app.example.com/reset?token=new"},
+		{"link with a fragment", "https://app.example.com/#/verify", "abc", "https://app.example.com/?token=abc#/verify"},
+		{"token needing escapes", "https://app.example.com/verify", "a+b/c=", "https://app.example.com/verify?token=a%2Bb%2Fc%3D"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := tokenLink(test.base, test.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != test.want {
+				t.Errorf("tokenLink = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"app/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type KeyService interface {
+	JWKS(c *fiber.Ctx) error
+}
+
+type implKeyService struct {
+	keys auth.KeyManager
+}
+
+func NewKeyService(keys auth.KeyManager) KeyService {
+	return &implKeyService{
+		keys: keys,
+	}
+}
+
+func (s *implKeyService) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return c.Status(fiber.StatusOK).JSON(s.keys.JWKS())
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"app/auth"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	loginSucceeded = "success"
+	loginFailed    = "failure"
+	loginLocked    = "locked"
+	loginThrottled = "throttled"
+)
+
+// reserveLoginAttempt answers 429 with a Retry-After header when the account
+// or the client IP has to wait before trying again. It returns nil when the
+// attempt may go ahead, which counts it as failed until releaseLoginAttempt
+// or loginSuccess says otherwise.
+func (s *implUserService) reserveLoginAttempt(c *fiber.Ctx, email string) error {
+	wait, err := s.throttle.Reserve(email, c.IP())
+	if errors.Is(err, auth.ErrThrottleContention) {
+		wait = time.Second
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	s.recordLoginEvent(c, email, nil, loginThrottled)
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"message": "too many login attempts, please try again later",
+	})
+}
+
+// releaseLoginAttempt hands back a reserved attempt whose credentials were
+// right, even though it did not end in a session.
+func (s *implUserService) releaseLoginAttempt(c *fiber.Ctx, email string) {
+	if err := s.throttle.Release(email, c.IP()); err != nil {
+		log.Printf("failed to release login attempt: %v", err)
+	}
+}
+
+func (s *implUserService) loginFailure(c *fiber.Ctx, email string, userID *string) {
+	locked, err := s.throttle.Failure(email)
+	if err != nil {
+		log.Printf("failed to record login failure: %v", err)
+	}
+
+	s.recordLoginEvent(c, email, userID, loginFailed)
+
+	if locked {
+		s.recordLoginEvent(c, email, userID, loginLocked)
+	}
+}
+
+func (s *implUserService) loginSuccess(c *fiber.Ctx, user *model.User) {
+	if err := s.throttle.Success(user.Email); err != nil {
+		log.Printf("failed to reset login attempts: %v", err)
+	}
+
+	s.recordLoginEvent(c, user.Email, &user.ID, loginSucceeded)
+}
+
+func (s *implUserService) recordLoginEvent(c *fiber.Ctx, email string, userID *string, outcome string) {
+	event := &model.LoginEvent{
+		UserID:    userID,
+		Email:     email,
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Outcome:   outcome,
+		CreatedAt: time.Now(),
+	}
+
+	if len(event.UserAgent) > 255 {
+		event.UserAgent = event.UserAgent[:255]
+	}
+
+	if len(event.Email) > 50 {
+		event.Email = event.Email[:50]
+	}
+
+	if err := s.db.Create(event).Error; err != nil {
+		log.Printf("failed to record login event: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"app/auth"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/v1/user/oauth"
+)
+
+var (
+	errOIDCEmailMissing = errors.New("provider did not share an email address")
+	errOIDCEmailInvalid = errors.New("provider shared an unusable email address")
+	errOIDCEmailTaken   = errors.New("email belongs to an existing account")
+	// whoever registered an unverified account may know its password
+	errOIDCAccountUnverified = errors.New("email belongs to an unverified account")
+)
+
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9._]+`)
+
+// OIDCLogin redirects the browser to the provider. The state, nonce and PKCE
+// verifier travel in a signed cookie scoped to the callback, so nothing has to
+// be stored server side before the user comes back.
+func (s *implUserService) OIDCLogin(c *fiber.Ctx) error {
+	provider, ok := s.providers[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "unknown provider",
+		})
+	}
+
+	state, err := auth.RandomToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to start login",
+		})
+	}
+
+	nonce, err := auth.RandomToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to start login",
+		})
+	}
+
+	verifier, challenge, err := auth.NewPKCEVerifier()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to start login",
+		})
+	}
+
+	redirectURL, err := provider.AuthCodeURL(c.UserContext(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("failed to build %s authorization url: %v", provider.Name(), err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"message": "provider is unavailable",
+		})
+	}
+
+	token, err := auth.IssuePurposeToken(s.keys, auth.PurposeOIDCState, &auth.PurposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: state},
+		Provider:         provider.Name(),
+		Nonce:            nonce,
+		CodeVerifier:     verifier,
+	}, s.config.OIDCStateTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to start login",
+		})
+	}
+
+	c.Cookie(s.oidcStateCookie(token, time.Now().Add(s.config.OIDCStateTTL)))
+
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
+
+func (s *implUserService) OIDCCallback(c *fiber.Ctx) error {
+	provider, ok := s.providers[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "unknown provider",
+		})
+	}
+
+	stateToken := c.Cookies(oidcStateCookie)
+
+	// the state is single use, whatever the outcome
+	c.Cookie(s.oidcStateCookie("", time.Now().Add(-time.Hour)))
+
+	if c.Query("error") != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "authorization was denied",
+		})
+	}
+
+	claims, err := auth.ParsePurposeToken(s.keys, auth.PurposeOIDCState, stateToken)
+	if err != nil || claims.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(claims.Subject), []byte(c.Query("state"))) != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid or expired login state",
+		})
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "missing authorization code",
+		})
+	}
+
+	identity, err := provider.Exchange(c.UserContext(), code, claims.CodeVerifier, claims.Nonce)
+	if err != nil {
+		log.Printf("%s login failed: %v", provider.Name(), err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "failed to authenticate with provider",
+		})
+	}
+
+	user, linked, err := s.userForIdentity(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCEmailMissing):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "provider did not share an email address",
+			})
+		case errors.Is(err, errOIDCEmailInvalid):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "provider shared an email address that cannot be used",
+			})
+		case errors.Is(err, errOIDCEmailTaken):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "an account with this email already exists, login with your password first",
+			})
+		case errors.Is(err, errOIDCAccountUnverified):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "an account with this email already exists, sign in with your password and verify your email to link the provider",
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "database error",
+			})
+		}
+	}
+
+	if linked {
+		s.recordUserEvent(c, "user.identity_link", user.ID, nil, fiber.Map{
+			"provider": identity.Provider,
+			"email":    identity.Email,
+		})
+	}
+
+	if user.SuspendedAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "account is suspended",
+		})
+	}
+
+	if s.config.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "email address has not been verified",
+		})
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return s.challengeMFA(c, user)
+	}
+
+	s.loginSuccess(c, user)
+
+	return s.startSession(c, user, false)
+}
+
+// oidcStateCookie is always SameSite=Lax, stricter settings would drop it on the
+// redirect back from the provider.
+func (s *implUserService) oidcStateCookie(value string, expires time.Time) *fiber.Cookie {
+	cookie := s.cookie(oidcStateCookie, value, oidcCookiePath, expires, true)
+	cookie.SameSite = fiber.CookieSameSiteLaxMode
+	return cookie
+}
+
+// userForIdentity returns the user linked to the identity. An unknown identity
+// is linked to the account with the same email when both the provider and the
+// account have verified that address, otherwise a new user is created just in
+// time. linked reports
+// whether a new link was made.
+func (s *implUserService) userForIdentity(identity *auth.OIDCIdentity) (user *model.User, linked bool, err error) {
+	user = &model.User{}
+
+	identity.Email = normalizeEmail(identity.Email)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		link := &model.UserIdentity{}
+
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(link).Error
+		if err == nil {
+			return tx.Where("id = ?", link.UserID).First(user).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if identity.Email == "" {
+			return errOIDCEmailMissing
+		}
+
+		// the address goes into the same columns registration limits to 50
+		if err := validator.New().Var(identity.Email, "email,max=50"); err != nil {
+			return errOIDCEmailInvalid
+		}
+
+		err = tx.Where("email = ?", identity.Email).First(user).Error
+		switch {
+		case err == nil:
+			// an unverified address could belong to someone else
+			if !identity.EmailVerified {
+				return errOIDCEmailTaken
+			}
+
+			if user.EmailVerifiedAt == nil {
+				return errOIDCAccountUnverified
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := s.createOIDCUser(tx, user, identity); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		linked = true
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return user, linked, nil
+}
+
+// createOIDCUser creates an account without a password. The user can set one
+// later through the password reset flow.
+func (s *implUserService) createOIDCUser(tx *gorm.DB, user *model.User, identity *auth.OIDCIdentity) error {
+	*user = model.User{
+		Username: oidcUsername(identity.Email),
+		Email:    identity.Email,
+	}
+
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+
+	role := &model.Role{}
+	if err := tx.Where("name = ?", "user").First(role).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(user).Association("Roles").Append(role)
+}
+
+func oidcUsername(email string) string {
+	name := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+	name = usernameUnsafe.ReplaceAllString(name, "")
+
+	if len(name) > 20 {
+		name = name[:20]
+	}
+	if name == "" {
+		name = "user"
+	}
+
+	return name + "_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+}
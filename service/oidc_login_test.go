@@ -0,0 +1,341 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"app/audit"
+	"app/auth"
+	"app/auth/oidctest"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const oidcCallbackURL = "http://app.test/api/v1/user/oauth/mock/callback"
+
+// newOIDCTestApp serves the OIDC login routes for the "mock" provider and an
+// "other" one. Without a database only the steps before the user lookup work.
+func newOIDCTestApp(t *testing.T, db *gorm.DB, provider *oidctest.Provider) *fiber.App {
+	t.Helper()
+
+	keys, err := auth.NewKeyManager(auth.KeyConfig{Algorithm: "HS256", SecretKey: []byte("test-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := map[string]auth.OIDCProvider{}
+	for _, name := range []string{"mock", "other"} {
+		providers[name] = auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    provider.URL,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  strings.Replace(oidcCallbackURL, "mock", name, 1),
+		}, nil)
+	}
+
+	var sessions auth.SessionManager
+	var recorder audit.Recorder
+
+	if db != nil {
+		sessions = auth.NewSessionManager(db, keys, auth.SessionConfig{
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+		})
+		recorder = audit.NewRecorder(db)
+	}
+
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), auth.LoginThrottleConfig{
+		FreeAttempts: 100,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Second,
+		ResetAfter:   time.Hour,
+	})
+
+	service := NewUserService(db, sessions, auth.NewMemoryRevocationStore(), keys, throttle, nil, nil, providers, nil, recorder, UserConfig{
+		OIDCStateTTL: 10 * time.Minute,
+		Cookies:      CookieConfig{SameSite: fiber.CookieSameSiteLaxMode},
+	})
+
+	app := fiber.New()
+	app.Get("/api/v1/user/oauth/:provider", service.OIDCLogin)
+	app.Get("/api/v1/user/oauth/:provider/callback", service.OIDCCallback)
+
+	return app
+}
+
+// startOIDCLogin returns the provider's authorization URL and the state cookie
+// the app set on the way there.
+func startOIDCLogin(t *testing.T, app *fiber.App, provider string) (string, *http.Cookie) {
+	t.Helper()
+
+	res, err := app.Test(httptest.NewRequest("GET", "/api/v1/user/oauth/"+provider, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != fiber.StatusFound {
+		t.Fatalf("login status = %d, want %d", res.StatusCode, fiber.StatusFound)
+	}
+
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return res.Header.Get("Location"), cookie
+		}
+	}
+
+	t.Fatal("login did not set the state cookie")
+	return "", nil
+}
+
+func oidcCallback(t *testing.T, app *fiber.App, provider string, query url.Values, cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/api/v1/user/oauth/"+provider+"/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+// completeOIDCLogin runs the whole flow and returns the callback response.
+func completeOIDCLogin(t *testing.T, app *fiber.App, provider *oidctest.Provider) *http.Response {
+	t.Helper()
+
+	login := authorizeOIDCLogin(t, app, provider, "mock")
+
+	return oidcCallback(t, app, "mock", url.Values{"code": {login.code}, "state": {login.state}}, login.cookie)
+}
+
+func TestOIDCLoginRedirect(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	app := newOIDCTestApp(t, nil, provider)
+
+	authURL, cookie := startOIDCLogin(t, app, "mock")
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+
+	if !strings.HasPrefix(authURL, provider.URL+"/authorize?") {
+		t.Errorf("redirect = %s, want the provider's authorization endpoint", authURL)
+	}
+
+	if query.Get("state") == "" || query.Get("nonce") == "" || query.Get("code_challenge") == "" {
+		t.Errorf("redirect is missing state, nonce or code challenge: %s", authURL)
+	}
+
+	if query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	if !cookie.HttpOnly || cookie.Path != oidcCookiePath || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("state cookie = %+v, want an HttpOnly SameSite=Lax cookie scoped to %s", cookie, oidcCookiePath)
+	}
+}
+
+// oidcLogin is a login that got as far as the provider's redirect back.
+type oidcLogin struct {
+	code   string
+	state  string
+	cookie *http.Cookie
+}
+
+func authorizeOIDCLogin(t *testing.T, app *fiber.App, provider *oidctest.Provider, name string) oidcLogin {
+	t.Helper()
+
+	authURL, cookie := startOIDCLogin(t, app, name)
+
+	code, state, err := provider.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return oidcLogin{code: code, state: state, cookie: cookie}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	provider := oidctest.NewProvider(t)
+	app := newOIDCTestApp(t, nil, provider)
+
+	tests := []struct {
+		name     string
+		callback func(login, other oidcLogin) (string, url.Values, *http.Cookie)
+		want     int
+	}{
+		{
+			name: "missing state cookie",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "mock", url.Values{"code": {login.code}, "state": {login.state}}, nil
+			},
+			want: fiber.StatusBadRequest,
+		},
+		{
+			name: "state does not match the cookie",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "mock", url.Values{"code": {login.code}, "state": {other.state}}, login.cookie
+			},
+			want: fiber.StatusBadRequest,
+		},
+		{
+			name: "tampered state cookie",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				tampered := *login.cookie
+				tampered.Value += "x"
+				return "mock", url.Values{"code": {login.code}, "state": {login.state}}, &tampered
+			},
+			want: fiber.StatusBadRequest,
+		},
+		{
+			name: "state of another provider",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "mock", url.Values{"code": {other.code}, "state": {other.state}}, other.cookie
+			},
+			want: fiber.StatusBadRequest,
+		},
+		{
+			name: "missing code",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "mock", url.Values{"state": {login.state}}, login.cookie
+			},
+			want: fiber.StatusBadRequest,
+		},
+		{
+			name: "provider denied the login",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "mock", url.Values{"error": {"access_denied"}, "state": {login.state}}, login.cookie
+			},
+			want: fiber.StatusUnauthorized,
+		},
+		{
+			name: "unknown provider",
+			callback: func(login, other oidcLogin) (string, url.Values, *http.Cookie) {
+				return "unknown", url.Values{"code": {login.code}, "state": {login.state}}, login.cookie
+			},
+			want: fiber.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			login := authorizeOIDCLogin(t, app, provider, "mock")
+			other := authorizeOIDCLogin(t, app, provider, "other")
+
+			name, query, cookie := test.callback(login, other)
+
+			if res := oidcCallback(t, app, name, query, cookie); res.StatusCode != test.want {
+				t.Errorf("status = %d, want %d", res.StatusCode, test.want)
+			}
+		})
+	}
+}
+
+func TestOIDCJustInTimeUser(t *testing.T) {
+	db := testDB(t)
+	provider := oidctest.NewProvider(t)
+	app := newOIDCTestApp(t, db, provider)
+
+	provider.User = oidctest.User{Subject: "jit-subject", Email: "Jit.User@Example.com", EmailVerified: true}
+
+	res := completeOIDCLogin(t, app, provider)
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("first login status = %d, want %d", res.StatusCode, fiber.StatusOK)
+	}
+
+	user := &model.User{}
+	if err := db.Preload("Roles").Where("email = ?", "jit.user@example.com").First(user).Error; err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+
+	if !strings.HasPrefix(user.Username, "jit.user_") || user.EmailVerifiedAt == nil || user.Password != "" {
+		t.Errorf("user = %+v, want a verified user without a password", user)
+	}
+
+	if len(user.Roles) != 1 || user.Roles[0].Name != "user" {
+		t.Errorf("roles = %v, want [user]", roleNames(user.Roles))
+	}
+
+	// the next login goes through the link, not the email
+	provider.User.Email = "renamed@example.com"
+
+	res = completeOIDCLogin(t, app, provider)
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("second login status = %d, want %d", res.StatusCode, fiber.StatusOK)
+	}
+
+	var links, users int64
+	db.Model(&model.UserIdentity{}).Where("provider = ? AND subject = ?", "mock", "jit-subject").Count(&links)
+	db.Model(&model.User{}).Where("email IN ?", []string{"jit.user@example.com", "renamed@example.com"}).Count(&users)
+
+	if links != 1 || users != 1 {
+		t.Errorf("links = %d, users = %d, want one of each", links, users)
+	}
+}
+
+func TestOIDCLinksExistingAccount(t *testing.T) {
+	tests := []struct {
+		name            string
+		email           string
+		verified        bool
+		accountVerified bool
+		want            int
+		wantLink        bool
+	}{
+		{"verified email links", "existing@example.com", true, true, fiber.StatusOK, true},
+		{"unverified email is refused", "existing@example.com", false, true, fiber.StatusConflict, false},
+		// its password may have been set by someone who registered the address first
+		{"unverified account is refused", "existing@example.com", true, false, fiber.StatusConflict, false},
+		{"email too long for the account", strings.Repeat("a", 50) + "@example.com", true, true, fiber.StatusBadRequest, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testDB(t)
+			provider := oidctest.NewProvider(t)
+			app := newOIDCTestApp(t, db, provider)
+
+			existing := &model.User{Username: "existing", Email: "existing@example.com", Password: "x"}
+			if test.accountVerified {
+				now := time.Now()
+				existing.EmailVerifiedAt = &now
+			}
+			if err := db.Create(existing).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			provider.User = oidctest.User{Subject: "link-subject", Email: test.email, EmailVerified: test.verified}
+
+			res := completeOIDCLogin(t, app, provider)
+			if res.StatusCode != test.want {
+				t.Fatalf("status = %d, want %d", res.StatusCode, test.want)
+			}
+
+			var links int64
+			db.Model(&model.UserIdentity{}).Where("user_id = ?", existing.ID).Count(&links)
+
+			if (links == 1) != test.wantLink {
+				t.Errorf("linked = %v, want %v", links == 1, test.wantLink)
+			}
+
+			db.First(existing, existing.ID)
+			if (existing.EmailVerifiedAt != nil) != test.accountVerified {
+				t.Errorf("account verified = %v, want it unchanged", existing.EmailVerifiedAt != nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"app/auth"
+	"app/mailer"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errInvalidResetToken = errors.New("invalid reset token")
+
+type ForgotPasswordStruct struct {
+	Email string `json:"email" validate:"required,max=50"`
+}
+
+func (s *implUserService) ForgotPassword(c *fiber.Ctx) error {
+	body := new(ForgotPasswordStruct)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	// the response is the same whether or not the email exists, so this
+	// endpoint cannot be used to enumerate accounts
+	response := fiber.Map{
+		"message": "if the email is registered, a reset link has been sent",
+	}
+
+	body.Email = normalizeEmail(body.Email)
+
+	user := &model.User{}
+
+	if err := s.db.Select("id", "email").Where("email = ?", body.Email).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.JSON(response)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	token, err := auth.RandomToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to create reset token",
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// only the most recent link works
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&model.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: auth.HashToken(token),
+			ExpiresAt: time.Now().Add(s.config.PasswordResetTTL),
+		}).Error
+	})
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to create reset token",
+		})
+	}
+
+	link, err := tokenLink(s.config.PasswordResetURL, token)
+	if err == nil {
+		err = s.mailer.Send(mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf(
+				"Use the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not ask for this, you can ignore this email.",
+				s.config.PasswordResetTTL, link,
+			),
+		})
+	}
+	if err != nil {
+		log.Printf("failed to send password reset email: %v", err)
+	}
+
+	return c.JSON(response)
+}
+
+type ResetPasswordStruct struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (s *implUserService) ResetPassword(c *fiber.Ctx) error {
+	body := new(ResetPasswordStruct)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	if violations := s.config.PasswordPolicy.Validate(body.Password); violations != nil {
+		return passwordPolicyError(c, violations)
+	}
+
+	hashedPassword, err := s.hasher.Hash(body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to hash password",
+		})
+	}
+
+	resetToken := &model.PasswordResetToken{}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", auth.HashToken(body.Token)).First(resetToken).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errInvalidResetToken
+			}
+			return err
+		}
+
+		// the conditional update makes the token single use even under concurrent requests
+		result := tx.Model(resetToken).
+			Where("used_at IS NULL AND expires_at > ?", time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		return tx.Model(&model.User{}).Where("id = ?", resetToken.UserID).Update("password", hashedPassword).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, errInvalidResetToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "invalid or expired reset token",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to reset password",
+		})
+	}
+
+	s.recordUserEvent(c, "user.password_reset", resetToken.UserID, nil, nil)
+
+	if err := s.revokeAllSessions(resetToken.UserID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to revoke existing sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password has been reset, please log in",
+	})
+}
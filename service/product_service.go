@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"app/audit"
 	"app/model"
 
 	"github.com/go-playground/validator/v10"
@@ -20,13 +21,19 @@ type ProductService interface {
 	DeleteProduct(c *fiber.Ctx) error
 }
 
+// reads serves the read-only handlers and may point to a replica, which can lag
+// behind. Lookups that precede a write use db.
 type implProductService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	reads *gorm.DB
+	audit audit.Recorder
 }
 
-func NewProductService(db *gorm.DB) ProductService {
+func NewProductService(db *gorm.DB, reads *gorm.DB, audit audit.Recorder) ProductService {
 	return &implProductService{
-		db: db,
+		db:    db,
+		reads: reads,
+		audit: audit,
 	}
 }
 
@@ -39,8 +46,12 @@ type ProductStruct struct {
 func (s *implProductService) CreateProduct(c *fiber.Ctx) error {
 	body := new(ProductStruct)
 
-	// user := c.Locals("user").(*model.User)
-	// fmt.Println("this is user", user.ID)
+	user, ok := c.Locals("user").(*model.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid session",
+		})
+	}
 
 	if err := c.BodyParser(body); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -57,17 +68,23 @@ func (s *implProductService) CreateProduct(c *fiber.Ctx) error {
 	}
 
 	product := &model.Product{
-		Name:       body.Name,
-		Price:      body.Price,
-		CategoryID: body.CategoryID,
+		Name:        body.Name,
+		Price:       body.Price,
+		CategoryID:  body.CategoryID,
+		CreatedByID: &user.ID,
 	}
 
 	if err := s.db.Create(product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "failed to create category",
-		})
+		return databaseError(c, err, "failed to create product")
 	}
 
+	s.audit.Record(c, audit.Event{
+		Action:       "product.create",
+		ResourceType: "product",
+		ResourceID:   fmt.Sprint(product.ID),
+		After:        product,
+	})
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "new product created",
 	})
@@ -76,7 +93,7 @@ func (s *implProductService) CreateProduct(c *fiber.Ctx) error {
 func (s *implProductService) GetAllProducts(c *fiber.Ctx) error {
 	products := make([]model.Product, 0)
 
-	if err := s.db.Preload("Category").Find(&products).Error; err != nil {
+	if err := s.reads.Preload("Category").Find(&products).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "unable to get all products",
 		})
@@ -103,7 +120,7 @@ func (s *implProductService) PaginatedProduct(c *fiber.Ctx) error {
 
 	products := make([]model.Product, 0)
 
-	query := s.db.Preload("Category").Model(&model.Product{}).Where("name ILIKE ?", "%"+search+"%")
+	query := s.reads.Preload("Category").Model(&model.Product{}).Where("name ILIKE ?", "%"+search+"%")
 
 	var totalRows int64
 	if err := query.Count(&totalRows).Error; err != nil {
@@ -139,7 +156,7 @@ func (s *implProductService) GetProductById(c *fiber.Ctx) error {
 
 	id := c.Params("id")
 
-	if err := s.db.Preload("Category").First(product, id).Error; err != nil {
+	if err := s.reads.Preload("Category").First(product, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"message": "Product not found",
@@ -172,6 +189,19 @@ func (s *implProductService) UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	before := &model.Product{}
+
+	if err := s.db.First(before, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Product not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
 	product := &model.Product{
 		Name:       body.Name,
 		Price:      body.Price,
@@ -184,12 +214,20 @@ func (s *implProductService) UpdateProduct(c *fiber.Ctx) error {
 				"message": "Product not found",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "database error",
-		})
+		return databaseError(c, err, "database error")
 	}
 
-	fmt.Println(product)
+	after := &model.Product{}
+
+	if err := s.db.First(after, id).Error; err == nil {
+		s.audit.Record(c, audit.Event{
+			Action:       "product.update",
+			ResourceType: "product",
+			ResourceID:   id,
+			Before:       before,
+			After:        after,
+		})
+	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "product updated",
@@ -201,6 +239,17 @@ func (s *implProductService) DeleteProduct(c *fiber.Ctx) error {
 
 	id := c.Params("id")
 
+	if err := s.db.First(product, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Product not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
 	if err := s.db.Delete(product, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -212,6 +261,13 @@ func (s *implProductService) DeleteProduct(c *fiber.Ctx) error {
 		})
 	}
 
+	s.audit.Record(c, audit.Event{
+		Action:       "product.delete",
+		ResourceType: "product",
+		ResourceID:   id,
+		Before:       product,
+	})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "product deleted",
 	})
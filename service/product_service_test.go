@@ -0,0 +1,39 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateWithoutUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler fiber.Handler
+		body    string
+	}{
+		{"product", NewProductService(nil, nil, nil).CreateProduct, `{"name":"Tea","price":2.5,"category_id":1}`},
+		{"category", NewCategoryService(nil, nil, nil).CreateCategory, `{"name":"Drinks"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", test.handler)
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != fiber.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", res.StatusCode, fiber.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"app/auth"
+	"app/mailer"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+var errEmailTaken = errors.New("email is already in use")
+
+// UserResponse is the only shape a user is ever returned in, so secrets such as
+// the password hash cannot leak through a new field on model.User.
+type UserResponse struct {
+	ID              string     `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PendingEmail    *string    `json:"pending_email"`
+	PhoneNumber     string     `json:"phone_number"`
+	Roles           []string   `json:"roles"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPEnabled     bool       `json:"totp_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func NewUserResponse(user *model.User, roles []string) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		PendingEmail:    user.PendingEmail,
+		PhoneNumber:     user.PhoneNumber,
+		Roles:           roles,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TOTPEnabled:     user.TOTPEnabledAt != nil,
+		CreatedAt:       user.CreatedAt,
+	}
+}
+
+func (s *implUserService) GetMe(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*model.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid session",
+		})
+	}
+
+	roles, err := auth.RoleNames(s.db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	return c.JSON(NewUserResponse(user, roles))
+}
+
+type UpdateMeStruct struct {
+	Username        *string `json:"username" validate:"omitempty,min=5,max=30"`
+	Email           *string `json:"email" validate:"omitempty,email,max=50"`
+	PhoneNumber     *string `json:"phone_number" validate:"omitempty,max=20"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+func (s *implUserService) UpdateMe(c *fiber.Ctx) error {
+	body := new(UpdateMeStruct)
+
+	user, ok := c.Locals("user").(*model.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid session",
+		})
+	}
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	if body.Email != nil {
+		*body.Email = normalizeEmail(*body.Email)
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	updates := map[string]interface{}{}
+
+	if body.Username != nil && *body.Username != user.Username {
+		taken, err := s.valueTaken(user.ID, "username = ?", *body.Username)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "database error",
+			})
+		}
+
+		if taken {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": "username is already taken",
+			})
+		}
+
+		updates["username"] = *body.Username
+	}
+
+	if body.PhoneNumber != nil {
+		updates["phone_number"] = *body.PhoneNumber
+	}
+
+	changeEmail := body.Email != nil && *body.Email != user.Email
+
+	if changeEmail {
+		if err := s.checkEmailChange(c, user, *body.Email, body.CurrentPassword); err != nil {
+			return err
+		}
+
+		updates["pending_email"] = *body.Email
+	} else if body.Email != nil && user.PendingEmail != nil {
+		// setting the current address again cancels a pending change
+		updates["pending_email"] = nil
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&model.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return databaseError(c, err, "failed to update profile")
+		}
+	}
+
+	if changeEmail {
+		if err := s.sendEmailChange(user, *body.Email); err != nil {
+			log.Printf("failed to send email change confirmation: %v", err)
+		}
+	}
+
+	updated := &model.User{}
+
+	if err := s.db.Where("id = ?", user.ID).First(updated).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	roles, err := auth.RoleNames(s.db, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	response := NewUserResponse(updated, roles)
+
+	s.recordUserEvent(c, "user.update", user.ID, NewUserResponse(user, roles), response)
+
+	return c.JSON(response)
+}
+
+// checkEmailChange answers the request itself and returns a non-nil error when
+// the email may not be changed to the given address.
+func (s *implUserService) checkEmailChange(c *fiber.Ctx, user *model.User, email, currentPassword string) error {
+	current := &model.User{}
+
+	if err := s.db.Select("id", "password").Where("id = ?", user.ID).First(current).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	// a stolen session alone must not be enough to take over the account
+	if current.Password != "" && !s.checkPassword(current, currentPassword) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "current password is incorrect",
+		})
+	}
+
+	taken, err := s.valueTaken(user.ID, "(email = ? OR pending_email = ?)", email, email)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if taken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "email is already in use",
+		})
+	}
+
+	return nil
+}
+
+func (s *implUserService) valueTaken(userID, query string, args ...interface{}) (bool, error) {
+	var count int64
+
+	err := s.db.Model(&model.User{}).Where("id <> ?", userID).Where(query, args...).Count(&count).Error
+
+	return count > 0, err
+}
+
+// sendEmailChange mails a confirmation link to the new address and a notice to
+// the old one. The address only changes once the link is opened.
+func (s *implUserService) sendEmailChange(user *model.User, email string) error {
+	token, err := auth.IssuePurposeToken(s.keys, auth.PurposeEmailChange, &auth.PurposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID},
+		Email:            email,
+	}, s.config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.config.EmailVerificationURL, token)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your new email address by opening the link below. It expires in %s.\n\n%s",
+			user.Username, s.config.EmailVerificationTTL, link,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA change of your account email to %s was requested. If this was not you, change your password right away.",
+			user.Username, email,
+		),
+	})
+}
+
+// confirmEmailChange swaps in the pending address the token was issued for.
+func (s *implUserService) confirmEmailChange(c *fiber.Ctx, claims *auth.PurposeClaims) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+
+		if err := tx.Model(&model.User{}).Where("id <> ? AND email = ?", claims.Subject, claims.Email).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return errEmailTaken
+		}
+
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND pending_email = ?", claims.Subject, claims.Email).
+			Updates(map[string]interface{}{
+				"email":             claims.Email,
+				"pending_email":     nil,
+				"email_verified_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return auth.ErrTokenInvalid
+		}
+
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		s.recordUserEvent(c, "user.email_change", claims.Subject, nil, fiber.Map{"email": claims.Email})
+
+		return c.JSON(fiber.Map{
+			"message": "email changed",
+		})
+	case errors.Is(err, errEmailTaken):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "email is already in use",
+		})
+	case errors.Is(err, auth.ErrTokenInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid verification link",
+		})
+	default:
+		return databaseError(c, err, "database error")
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"app/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const refreshCookiePath = "/api/v1/user"
+
+type CookieConfig struct {
+	SameSite string
+	Secure   bool
+	Domain   string
+}
+
+func sessionMeta(c *fiber.Ctx) auth.SessionMeta {
+	return auth.SessionMeta{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IPAddress: c.IP(),
+	}
+}
+
+// setSessionCookies stores the token pair in HttpOnly cookies and returns the
+// CSRF token that mutating requests have to send back in a header.
+func (s *implUserService) setSessionCookies(c *fiber.Ctx, pair *auth.TokenPair) (string, error) {
+	csrfToken, err := auth.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	c.Cookie(s.cookie("token", pair.AccessToken, "", pair.AccessExpiresAt, true))
+	c.Cookie(s.cookie("refresh_token", pair.RefreshToken, refreshCookiePath, pair.RefreshExpiresAt, true))
+
+	// readable by scripts on purpose, that is what makes double-submit work
+	c.Cookie(s.cookie(auth.CSRFCookieName, csrfToken, "", pair.RefreshExpiresAt, false))
+
+	return csrfToken, nil
+}
+
+func (s *implUserService) clearSessionCookies(c *fiber.Ctx) {
+	expired := time.Now().Add(-time.Hour)
+
+	c.Cookie(s.cookie("token", "", "", expired, true))
+	c.Cookie(s.cookie("refresh_token", "", refreshCookiePath, expired, true))
+	c.Cookie(s.cookie(auth.CSRFCookieName, "", "", expired, false))
+}
+
+func (s *implUserService) cookie(name, value, path string, expires time.Time, httpOnly bool) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.config.Cookies.Domain,
+		Expires:  expires,
+		Secure:   s.config.Cookies.Secure,
+		HTTPOnly: httpOnly,
+		SameSite: s.config.Cookies.SameSite,
+	}
+}
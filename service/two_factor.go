@@ -0,0 +1,453 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"app/auth"
+	"app/model"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	errInvalidSecondFactor = errors.New("invalid second factor")
+	errMFAChallengeUsed    = errors.New("mfa challenge already used or expired")
+)
+
+func (s *implUserService) EnrollTOTP(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	user := &model.User{}
+
+	if err := s.db.Select("id", "email", "totp_enabled_at").Where("id = ?", userID).First(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to generate secret",
+		})
+	}
+
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to generate secret",
+		})
+	}
+
+	// the secret stays pending until ConfirmTOTP proves the app was set up
+	if err := s.db.Model(user).Updates(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	uri := auth.TOTPProvisioningURI(s.config.TOTPIssuer, user.Email, secret)
+
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to generate QR code",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":           secret,
+		"provisioning_uri": uri,
+		"qr_code":          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+type TOTPCodeStruct struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+func (s *implUserService) ConfirmTOTP(c *fiber.Ctx) error {
+	body := new(TOTPCodeStruct)
+
+	userID, _ := c.Locals("user_id").(string)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	user := &model.User{}
+
+	if err := s.db.Select("id", "totp_secret", "totp_enabled_at").Where("id = ?", userID).First(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if user.TOTPEnabledAt != nil || user.TOTPSecret == "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "no pending two-factor enrollment",
+		})
+	}
+
+	secret, err := s.secrets.Open(user.TOTPSecret)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to read secret",
+		})
+	}
+
+	step, ok := auth.ValidateTOTP(secret, body.Code, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "invalid code",
+		})
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to generate recovery codes",
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(tx, user.ID, codes)
+	})
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to enable two-factor authentication",
+		})
+	}
+
+	s.recordUserEvent(c, "user.2fa_enable", user.ID, nil, nil)
+
+	return c.JSON(fiber.Map{
+		"message":        "two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+type DisableTOTPStruct struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (s *implUserService) DisableTOTP(c *fiber.Ctx) error {
+	body := new(DisableTOTPStruct)
+
+	userID, _ := c.Locals("user_id").(string)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	user := &model.User{}
+
+	if err := s.db.Where("id = ?", userID).First(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "two-factor authentication is not enabled",
+		})
+	}
+
+	if !s.checkPassword(user, body.Password) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "current password is incorrect",
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkSecondFactor(tx, user, body.Code, body.RecoveryCode); err != nil {
+			return err
+		}
+
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "invalid code",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to disable two-factor authentication",
+		})
+	}
+
+	s.recordUserEvent(c, "user.2fa_disable", user.ID, nil, nil)
+
+	return c.JSON(fiber.Map{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// challengeMFA hands out a token for the second step of the login. It names a
+// stored challenge, so it can only complete one login.
+func (s *implUserService) challengeMFA(c *fiber.Ctx, user *model.User) error {
+	challenge := &model.MFAChallenge{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.config.MFAChallengeTTL),
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND (used_at IS NOT NULL OR expires_at < ?)", user.ID, time.Now()).Delete(&model.MFAChallenge{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(challenge).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to login",
+		})
+	}
+
+	token, err := auth.IssuePurposeToken(s.keys, auth.PurposeMFAChallenge, &auth.PurposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID, ID: challenge.ID},
+	}, s.config.MFAChallengeTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to login",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "two-factor authentication required",
+		"mfa_required": true,
+		"mfa_token":    token,
+	})
+}
+
+type VerifyLoginStruct struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	ReturnToken  bool   `json:"return_token"`
+}
+
+func (s *implUserService) VerifyLogin(c *fiber.Ctx) error {
+	body := new(VerifyLoginStruct)
+
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "invalid data",
+		})
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "validation error",
+		})
+	}
+
+	claims, err := auth.ParsePurposeToken(s.keys, auth.PurposeMFAChallenge, body.MFAToken)
+	if err != nil || claims.ID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "your login attempt has expired, please log in again",
+		})
+	}
+
+	user := &model.User{}
+
+	if err := s.db.Where("id = ?", claims.Subject).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "invalid code",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	// codes are guessable, so they count against the same limits as passwords
+	if err := s.reserveLoginAttempt(c, user.Email); err != nil {
+		return err
+	}
+
+	// the challenge may have been handed out before the account was suspended
+	if user.SuspendedAt != nil {
+		s.releaseLoginAttempt(c, user.Email)
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "account is suspended",
+		})
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if user.TOTPEnabledAt == nil {
+			return errInvalidSecondFactor
+		}
+
+		// a wrong code rolls this back, leaving the challenge for another try
+		now := time.Now()
+		result := tx.Model(&model.MFAChallenge{}).
+			Where("id = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", claims.ID, user.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errMFAChallengeUsed
+		}
+
+		return s.checkSecondFactor(tx, user, body.Code, body.RecoveryCode)
+	})
+
+	if err != nil {
+		if errors.Is(err, errMFAChallengeUsed) {
+			s.releaseLoginAttempt(c, user.Email)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "your login attempt has expired, please log in again",
+			})
+		}
+		if errors.Is(err, errInvalidSecondFactor) {
+			s.loginFailure(c, user.Email, &user.ID)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "invalid code",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	s.releaseLoginAttempt(c, user.Email)
+	s.loginSuccess(c, user)
+
+	return s.startSession(c, user, body.ReturnToken)
+}
+
+// checkSecondFactor accepts either a TOTP code or a recovery code and burns
+// whichever was used, so neither can be replayed.
+func (s *implUserService) checkSecondFactor(tx *gorm.DB, user *model.User, code, recoveryCode string) error {
+	if code != "" {
+		secret, err := s.secrets.Open(user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+
+		step, ok := auth.ValidateTOTP(secret, code, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+
+		updates := map[string]interface{}{"totp_last_step": step}
+
+		// secrets enrolled before they were encrypted are sealed on first use
+		if !auth.IsSealed(user.TOTPSecret) {
+			sealed, err := s.secrets.Seal(secret)
+			if err != nil {
+				return err
+			}
+			updates["totp_secret"] = sealed
+		}
+
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errInvalidSecondFactor
+		}
+
+		return nil
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&model.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errInvalidSecondFactor
+		}
+
+		return nil
+	}
+
+	return errInvalidSecondFactor
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	recoveryCodes := make([]model.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		recoveryCodes = append(recoveryCodes, model.RecoveryCode{
+			UserID:   userID,
+			CodeHash: auth.HashToken(code),
+		})
+	}
+
+	return tx.Create(&recoveryCodes).Error
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"app/auth"
+	"app/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newMFATestApp serves VerifyLogin and a route that starts the second step of
+// a login for user.
+func newMFATestApp(t *testing.T, user *model.User) (*fiber.App, auth.KeyManager) {
+	t.Helper()
+
+	db := testDB(t)
+
+	keys, err := auth.NewKeyManager(auth.KeyConfig{Algorithm: "HS256", SecretKey: []byte("test-secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := auth.NewSecretBox(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), auth.LoginThrottleConfig{
+		FreeAttempts: 100,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Second,
+		ResetAfter:   time.Hour,
+	})
+
+	sessions := auth.NewSessionManager(db, keys, auth.SessionConfig{
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+
+	service := NewUserService(db, sessions, auth.NewMemoryRevocationStore(), keys, throttle, nil, secrets, nil, nil, nil, UserConfig{
+		MFAChallengeTTL: time.Minute,
+		Cookies:         CookieConfig{SameSite: fiber.CookieSameSiteLaxMode},
+	}).(*implUserService)
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	if user.TOTPSecret, err = secrets.Seal("JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceRecoveryCodes(db, user.ID, []string{"aaaaa-aaaaa", "bbbbb-bbbbb"}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/challenge", func(c *fiber.Ctx) error { return service.challengeMFA(c, user) })
+	app.Post("/verify", service.VerifyLogin)
+
+	return app, keys
+}
+
+func postJSON(t *testing.T, app *fiber.App, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", path, bytes.NewReader(encoded))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := map[string]interface{}{}
+	json.NewDecoder(res.Body).Decode(&decoded)
+
+	return res.StatusCode, decoded
+}
+
+func TestVerifyLoginChallengeIsSingleUse(t *testing.T) {
+	user := &model.User{Username: "mfa", Email: "mfa@example.com", Password: "x"}
+	app, _ := newMFATestApp(t, user)
+
+	_, challenge := postJSON(t, app, "/challenge", nil)
+	token, _ := challenge["mfa_token"].(string)
+	if token == "" {
+		t.Fatalf("challenge = %v, want an mfa_token", challenge)
+	}
+
+	steps := []struct {
+		name         string
+		recoveryCode string
+		want         int
+	}{
+		{"wrong code keeps the challenge", "zzzzz-zzzzz", fiber.StatusUnauthorized},
+		{"right code completes the login", "aaaaa-aaaaa", fiber.StatusOK},
+		{"challenge cannot be used again", "bbbbb-bbbbb", fiber.StatusUnauthorized},
+	}
+
+	for _, step := range steps {
+		status, body := postJSON(t, app, "/verify", VerifyLoginStruct{MFAToken: token, RecoveryCode: step.recoveryCode, ReturnToken: true})
+		if status != step.want {
+			t.Fatalf("%s: status = %d (%v), want %d", step.name, status, body, step.want)
+		}
+	}
+
+	// the unused recovery code was not burnt by the refused replay
+	_, challenge = postJSON(t, app, "/challenge", nil)
+	token, _ = challenge["mfa_token"].(string)
+
+	if status, body := postJSON(t, app, "/verify", VerifyLoginStruct{MFAToken: token, RecoveryCode: "bbbbb-bbbbb", ReturnToken: true}); status != fiber.StatusOK {
+		t.Fatalf("new challenge: status = %d (%v), want %d", status, body, fiber.StatusOK)
+	}
+}
+
+func TestVerifyLoginRejectsTokenWithoutChallenge(t *testing.T) {
+	user := &model.User{Username: "mfa", Email: "mfa@example.com", Password: "x"}
+	app, keys := newMFATestApp(t, user)
+
+	token, err := auth.IssuePurposeToken(keys, auth.PurposeMFAChallenge, &auth.PurposeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID},
+	}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := postJSON(t, app, "/verify", VerifyLoginStruct{MFAToken: token, RecoveryCode: "aaaaa-aaaaa", ReturnToken: true})
+	if status != fiber.StatusUnauthorized || !strings.Contains(body["message"].(string), "expired") {
+		t.Errorf("status = %d (%v), want %d", status, body, fiber.StatusUnauthorized)
+	}
+}
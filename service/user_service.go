@@ -1,32 +1,82 @@
 package service
 
 import (
-	"fmt"
-	"os"
+	"errors"
+	"log"
 	"time"
 
+	"app/audit"
+	"app/auth"
+	"app/mailer"
 	"app/model"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type UserService interface {
 	Register(c *fiber.Ctx) error
 	Login(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	LogoutAll(c *fiber.Ctx) error
 	UpdatePassword(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerification(c *fiber.Ctx) error
+	EnrollTOTP(c *fiber.Ctx) error
+	ConfirmTOTP(c *fiber.Ctx) error
+	DisableTOTP(c *fiber.Ctx) error
+	VerifyLogin(c *fiber.Ctx) error
+	OIDCLogin(c *fiber.Ctx) error
+	OIDCCallback(c *fiber.Ctx) error
+	GetMe(c *fiber.Ctx) error
+	UpdateMe(c *fiber.Ctx) error
+}
+
+type UserConfig struct {
+	PasswordResetURL                string
+	PasswordResetTTL                time.Duration
+	EmailVerificationURL            string
+	EmailVerificationTTL            time.Duration
+	EmailVerificationResendInterval time.Duration
+	RequireEmailVerification        bool
+	PasswordPolicy                  auth.PasswordPolicy
+	TOTPIssuer                      string
+	MFAChallengeTTL                 time.Duration
+	OIDCStateTTL                    time.Duration
+	Cookies                         CookieConfig
 }
 
 type implUserService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	sessions    auth.SessionManager
+	revocations auth.RevocationStore
+	keys        auth.KeyManager
+	throttle    auth.LoginThrottle
+	hasher      auth.PasswordHasher
+	secrets     auth.SecretBox
+	providers   map[string]auth.OIDCProvider
+	mailer      mailer.Mailer
+	audit       audit.Recorder
+	config      UserConfig
 }
 
-func NewUserService(db *gorm.DB) UserService {
+func NewUserService(db *gorm.DB, sessions auth.SessionManager, revocations auth.RevocationStore, keys auth.KeyManager, throttle auth.LoginThrottle, hasher auth.PasswordHasher, secrets auth.SecretBox, providers map[string]auth.OIDCProvider, mailer mailer.Mailer, audit audit.Recorder, config UserConfig) UserService {
 	return &implUserService{
-		db: db,
+		db:          db,
+		sessions:    sessions,
+		revocations: revocations,
+		keys:        keys,
+		throttle:    throttle,
+		hasher:      hasher,
+		secrets:     secrets,
+		providers:   providers,
+		mailer:      mailer,
+		audit:       audit,
+		config:      config,
 	}
 }
 
@@ -34,7 +84,7 @@ type RegisterStruct struct {
 	Username     string `json:"username" validate:"required,min=5,max=30"`
 	Email        string `json:"email" validate:"required,max=50"`
 	Phone_Number string `json:"phone_number" validate:"required,max=20"`
-	Password     string `json:"password" validate:"required,min=5,max=20"`
+	Password     string `json:"password" validate:"required"`
 }
 
 func (s *implUserService) Register(c *fiber.Ctx) error {
@@ -54,7 +104,13 @@ func (s *implUserService) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if violations := s.config.PasswordPolicy.Validate(body.Password); violations != nil {
+		return passwordPolicyError(c, violations)
+	}
+
+	body.Email = normalizeEmail(body.Email)
+
+	hashedPassword, err := s.hasher.Hash(body.Password)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to hash password",
@@ -65,18 +121,31 @@ func (s *implUserService) Register(c *fiber.Ctx) error {
 		Username:    body.Username,
 		Email:       body.Email,
 		PhoneNumber: body.Phone_Number,
-		Password:    string(hashedPassword),
+		Password:    hashedPassword,
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
-		fmt.Println("this is the error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "failed to register",
-			"error":   err.Error(),
-		})
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		role := &model.Role{}
+		if err := tx.Where("name = ?", "user").First(role).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(user).Association("Roles").Append(role)
+	})
+
+	if err != nil {
+		return databaseError(c, err, "failed to register")
 	}
 
-	fmt.Println(user, hashedPassword)
+	s.recordUserEvent(c, "user.register", user.ID, nil, NewUserResponse(user, []string{"user"}))
+
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("failed to send verification email: %v", err)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "registration success",
@@ -84,8 +153,9 @@ func (s *implUserService) Register(c *fiber.Ctx) error {
 }
 
 type LoginStruct struct {
-	Email    string `json:"email" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Email       string `json:"email" validate:"required"`
+	Password    string `json:"password" validate:"required"`
+	ReturnToken bool   `json:"return_token"`
 }
 
 func (s *implUserService) Login(c *fiber.Ctx) error {
@@ -105,10 +175,17 @@ func (s *implUserService) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	body.Email = normalizeEmail(body.Email)
+
+	if err := s.reserveLoginAttempt(c, body.Email); err != nil {
+		return err
+	}
+
 	user := &model.User{}
 
 	if err := s.db.Where("email = ?", body.Email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			s.loginFailure(c, body.Email, nil)
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"message": "invalid username or password",
 			})
@@ -118,44 +195,192 @@ func (s *implUserService) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+	if !s.checkPassword(user, body.Password) {
+		s.loginFailure(c, body.Email, &user.ID)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"message": "invalid username or password",
 		})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(time.Hour * 1).Unix(), // Token expires in 24 hours
-	})
+	// the password was right, whatever happens next is not a failed guess
+	s.releaseLoginAttempt(c, body.Email)
+
+	if user.SuspendedAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "account is suspended",
+		})
+	}
+
+	if s.config.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "email address has not been verified",
+		})
+	}
+
+	s.upgradePasswordHash(user, body.Password)
+
+	// the counters are only cleared once the second factor has been checked too
+	if user.TOTPEnabledAt != nil {
+		return s.challengeMFA(c, user)
+	}
+
+	s.loginSuccess(c, user)
 
-	signedToken, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+	return s.startSession(c, user, body.ReturnToken)
+}
+
+func (s *implUserService) startSession(c *fiber.Ctx, user *model.User, returnToken bool) error {
+	pair, err := s.sessions.Create(user, sessionMeta(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "failed to login",
 		})
 	}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "token",
-		Value:    signedToken,
-		Expires:  time.Now().Add(time.Hour * 24),
-		HTTPOnly: true,
+	if returnToken {
+		return c.JSON(fiber.Map{
+			"message": "login successful",
+			"token":   pair,
+		})
+	}
+
+	csrfToken, err := s.setSessionCookies(c, pair)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to login",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    "login successful",
+		"csrf_token": csrfToken,
 	})
+}
+
+type RefreshStruct struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *implUserService) Refresh(c *fiber.Ctx) error {
+	refreshToken := c.Cookies("refresh_token")
+	fromBody := false
+
+	if refreshToken == "" {
+		body := new(RefreshStruct)
+
+		if err := c.BodyParser(body); err == nil {
+			refreshToken = body.RefreshToken
+			fromBody = true
+		}
+	}
+
+	if refreshToken == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "missing refresh token",
+		})
+	}
+
+	pair, err := s.sessions.Rotate(refreshToken, sessionMeta(c))
+	if err != nil {
+		if errors.Is(err, auth.ErrAccountSuspended) {
+			s.clearSessionCookies(c)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "account is suspended",
+			})
+		}
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			s.clearSessionCookies(c)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "invalid session",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to refresh session",
+		})
+	}
+
+	// clients that keep their tokens out of the cookie jar get them back the same way
+	if fromBody {
+		return c.JSON(fiber.Map{
+			"message": "session refreshed",
+			"token":   pair,
+		})
+	}
+
+	csrfToken, err := s.setSessionCookies(c, pair)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to refresh session",
+		})
+	}
 
 	return c.JSON(fiber.Map{
-		"message": "login successful",
+		"message":    "session refreshed",
+		"csrf_token": csrfToken,
 	})
 }
 
+func (s *implUserService) Logout(c *fiber.Ctx) error {
+	tokenID, _ := c.Locals("token_id").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	expiresAt, _ := c.Locals("token_expires_at").(time.Time)
+
+	if tokenID != "" {
+		if err := s.revocations.RevokeToken(tokenID, expiresAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "failed to logout",
+			})
+		}
+	}
+
+	if sessionID != "" {
+		if err := s.sessions.Revoke(sessionID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "failed to logout",
+			})
+		}
+	}
+
+	s.clearSessionCookies(c)
+
+	return c.JSON(fiber.Map{
+		"message": "logout successful",
+	})
+}
+
+func (s *implUserService) LogoutAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(string)
+
+	if err := s.revokeAllSessions(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "failed to logout",
+		})
+	}
+
+	s.clearSessionCookies(c)
+
+	return c.JSON(fiber.Map{
+		"message": "logged out from all devices",
+	})
+}
+
+func (s *implUserService) revokeAllSessions(userID string) error {
+	if err := s.revocations.RevokeUser(userID, time.Now()); err != nil {
+		return err
+	}
+
+	return s.sessions.RevokeAll(userID)
+}
+
 type PasswordStruct struct {
-	Password string `json:"password" validate:"required,min=5,max=20"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
 }
 
 func (s *implUserService) UpdatePassword(c *fiber.Ctx) error {
 	body := new(PasswordStruct)
 
-	userID := c.Locals("user_id")
+	userID, _ := c.Locals("user_id").(string)
 
 	if err := c.BodyParser(body); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -171,22 +396,111 @@ func (s *implUserService) UpdatePassword(c *fiber.Ctx) error {
 		})
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	user := &model.User{}
+
+	if err := s.db.Select("id", "password").Where("id = ?", userID).First(user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Invalid session",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "database error",
+		})
+	}
+
+	if !s.checkPassword(user, body.CurrentPassword) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "current password is incorrect",
+		})
+	}
+
+	if violations := s.config.PasswordPolicy.Validate(body.Password); violations != nil {
+		return passwordPolicyError(c, violations)
+	}
+
+	hashedPassword, err := s.hasher.Hash(body.Password)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to hash password",
 		})
 	}
 
-	user := &model.User{}
-
-	if err := s.db.Model(&user).Where("id = ?", userID).Update("password", string(hashedPassword)).Error; err != nil {
+	if err := s.db.Model(&user).Where("id = ?", userID).Update("password", hashedPassword).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Failed to update password",
 		})
 	}
 
+	s.recordUserEvent(c, "user.password_change", userID, nil, nil)
+
+	if err := s.revokeAllSessions(userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to revoke existing sessions",
+		})
+	}
+
+	s.clearSessionCookies(c)
+
 	return c.JSON(fiber.Map{
-		"message": "Password updated successfully",
+		"message": "Password updated successfully, please log in again",
+	})
+}
+
+// recordUserEvent audits an action users take on their own account.
+func (s *implUserService) recordUserEvent(c *fiber.Ctx, action, userID string, before, after interface{}) {
+	s.audit.Record(c, audit.Event{
+		ActorID:      userID,
+		Action:       action,
+		ResourceType: "user",
+		ResourceID:   userID,
+		Before:       before,
+		After:        after,
+	})
+}
+
+func (s *implUserService) checkPassword(user *model.User, password string) bool {
+	// accounts created through an OIDC provider have no password
+	if user.Password == "" {
+		return false
+	}
+
+	ok, err := s.hasher.Verify(password, user.Password)
+	if err != nil {
+		log.Printf("failed to verify password hash for user %s: %v", user.ID, err)
+		return false
+	}
+	return ok
+}
+
+// upgradePasswordHash re-hashes the password with the current algorithm and
+// parameters while the plain text is at hand. It only replaces the hash it
+// verified, so it cannot undo a concurrent password change.
+func (s *implUserService) upgradePasswordHash(user *model.User, password string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("failed to rehash password for user %s: %v", user.ID, err)
+		return
+	}
+
+	err = s.db.Model(&model.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Update("password", hashedPassword).Error
+	if err != nil {
+		log.Printf("failed to store rehashed password for user %s: %v", user.ID, err)
+		return
+	}
+
+	user.Password = hashedPassword
+}
+
+func passwordPolicyError(c *fiber.Ctx, violations []string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"message": "password does not meet the policy",
+		"errors":  violations,
 	})
 }